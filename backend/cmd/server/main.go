@@ -3,27 +3,100 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	gcs "cloud.google.com/go/storage"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 
 	"github.com/worldlabs/image-grid-viewer/backend/config"
 	"github.com/worldlabs/image-grid-viewer/backend/service"
 	"github.com/worldlabs/image-grid-viewer/backend/storage"
+	"github.com/worldlabs/image-grid-viewer/backend/thumbnail"
 )
 
 func main() {
 	cfg := config.Load()
+	switch cfg.ObjectURLs {
+	case config.ObjectURLsProxy, config.ObjectURLsPublic:
+	case config.ObjectURLsSigned:
+		if cfg.SignedURLExpiry <= 0 || cfg.SignedURLExpiry > storage.MaxSignedURLExpiry {
+			log.Fatalf("SIGNED_URL_EXPIRY must be between 0 and %s", storage.MaxSignedURLExpiry)
+		}
+	default:
+		log.Fatalf("unknown OBJECT_URLS %q", cfg.ObjectURLs)
+	}
 	httpClient := &http.Client{Timeout: cfg.RequestTimeout}
-	storageClient := storage.NewHTTPClient(httpClient)
-	querySvc := service.NewQueryService(cfg, storageClient)
+	s3Client, err := storage.NewS3Client(httpClient, storage.S3Config{
+		Endpoint:        cfg.S3Endpoint,
+		Region:          cfg.S3Region,
+		AccessKeyID:     cfg.S3AccessKeyID,
+		SecretAccessKey: cfg.S3SecretAccessKey,
+		SessionToken:    cfg.S3SessionToken,
+		PathStyle:       cfg.S3PathStyle,
+	})
+	if err != nil {
+		log.Fatalf("S3 storage error: %v", err)
+	}
+	gcsClient, err := newGCSClient(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("GCS storage error: %v", err)
+	}
+
+	retryPolicy := storage.RetryPolicy{
+		MaxAttempts:    cfg.RetryAttempts,
+		InitialBackoff: cfg.RetryBackoff,
+		MaxBackoff:     cfg.RetryMaxBackoff,
+	}
+
+	cachePolicy := storage.CachePolicy{
+		TTL:        cfg.ListCacheTTL,
+		MaxEntries: cfg.ListCacheSize,
+		MaxObjects: cfg.ListCacheObjects,
+		Timeout:    cfg.ListCacheTimeout,
+	}
+	cached := func(client storage.Client) storage.Client {
+		if cachePolicy.TTL <= 0 {
+			return client
+		}
+		return storage.NewCachingClient(client, cachePolicy)
+	}
+
+	registry := storage.NewRegistry()
+	registry.Register(storage.SchemeGCS, cached(storage.NewRetryClient(gcsClient, retryPolicy)))
+	registry.Register(storage.SchemeS3, cached(storage.NewRetryClient(s3Client, retryPolicy)))
+	if cfg.FSRoot != "" {
+		fsClient, err := storage.NewFSClient(cfg.FSRoot)
+		if err != nil {
+			log.Fatalf("Filesystem storage error: %v", err)
+		}
+		registry.Register(storage.SchemeFile, cached(fsClient))
+	}
+	if cfg.MemFixture != "" {
+		memClient, err := storage.LoadMemoryFixture(cfg.MemFixture)
+		if err != nil {
+			log.Fatalf("Memory storage error: %v", err)
+		}
+		registry.Register(storage.SchemeMemory, memClient)
+	}
+	querySvc := service.NewQueryService(cfg, registry)
+
+	thumbCache, err := thumbnail.NewDiskCache(cfg.ThumbCacheDir, int64(cfg.ThumbCacheMaxMB)<<20)
+	if err != nil {
+		log.Fatalf("Thumbnail cache error: %v", err)
+	}
+	thumbs := thumbnail.New(thumbCache, cfg.ThumbConcurrency)
 
 	router := mux.NewRouter()
 	api := router.PathPrefix("/api").Subrouter()
@@ -34,6 +107,18 @@ func main() {
 	api.HandleFunc("/count", func(w http.ResponseWriter, r *http.Request) {
 		countHandler(querySvc, w, r)
 	}).Methods("POST")
+	api.HandleFunc("/explain", func(w http.ResponseWriter, r *http.Request) {
+		explainHandler(querySvc, w, r)
+	}).Methods("POST")
+	api.HandleFunc("/facets", func(w http.ResponseWriter, r *http.Request) {
+		facetsHandler(querySvc, w, r)
+	}).Methods("POST")
+	api.HandleFunc("/object", func(w http.ResponseWriter, r *http.Request) {
+		objectHandler(querySvc, cfg.ObjectMaxAge, w, r)
+	}).Methods("GET", "HEAD")
+	api.HandleFunc("/thumb", func(w http.ResponseWriter, r *http.Request) {
+		thumbHandler(querySvc, thumbs, cfg.ThumbWidth, cfg.ObjectMaxAge, w, r)
+	}).Methods("GET")
 
 	c := cors.New(cors.Options{
 		AllowedOrigins:   cfg.AllowedOrigins,
@@ -71,6 +156,59 @@ func main() {
 	log.Println("Server stopped")
 }
 
+// newGCSClient builds the client for gs:// patterns. STORAGE_BACKEND selects
+// the anonymous JSON API client ("http") or the cloud storage SDK ("sdk");
+// both can be pointed at an emulator such as fake-gcs-server.
+func newGCSClient(ctx context.Context, cfg config.Config) (storage.Client, error) {
+	gcsCfg := storage.GCSConfig{
+		BaseURL:     cfg.GCSBaseURL,
+		UserProject: cfg.GCSUserProject,
+	}
+	if gcsCfg.BaseURL == "" && cfg.GCSEmulatorHost != "" {
+		gcsCfg.BaseURL = storage.EmulatorBaseURL(cfg.GCSEmulatorHost)
+	}
+	if cfg.ObjectURLs == config.ObjectURLsSigned && cfg.GCSSigningKeyFile != "" {
+		key, err := storage.LoadServiceAccountKey(cfg.GCSSigningKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		gcsCfg.SigningKey = key
+	}
+
+	switch cfg.StorageBackend {
+	case config.StorageBackendHTTP:
+		httpClient := &http.Client{Timeout: cfg.RequestTimeout}
+		if cfg.GCSCredentialsFile != "" {
+			transport, err := htransport.NewTransport(ctx, http.DefaultTransport,
+				option.WithAuthCredentialsFile(option.ServiceAccount, cfg.GCSCredentialsFile),
+				option.WithScopes(gcs.ScopeReadOnly))
+			if err != nil {
+				return nil, err
+			}
+			httpClient.Transport = transport
+		}
+		return storage.NewHTTPClient(httpClient, gcsCfg), nil
+	case config.StorageBackendSDK:
+		opts := []option.ClientOption{option.WithScopes(gcs.ScopeReadOnly)}
+		switch {
+		case cfg.GCSCredentialsFile != "":
+			opts = append(opts, option.WithAuthCredentialsFile(option.ServiceAccount, cfg.GCSCredentialsFile))
+		case cfg.GCSEmulatorHost != "":
+			opts = append(opts, option.WithoutAuthentication())
+		}
+		if gcsCfg.BaseURL != "" {
+			opts = append(opts, option.WithEndpoint(gcsCfg.BaseURL+"/"))
+		}
+		client, err := gcs.NewClient(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewGCSClient(client, gcsCfg), nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", cfg.StorageBackend)
+	}
+}
+
 // Health check endpoint
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -83,17 +221,13 @@ func queryHandler(svc *service.QueryService, w http.ResponseWriter, r *http.Requ
 
 	var req service.QueryRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		writeError(w, service.ClientError{Msg: "invalid request body"})
 		return
 	}
 
 	resp, err := svc.Query(r.Context(), req)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if service.IsClientError(err) {
-			status = http.StatusBadRequest
-		}
-		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), status)
+		writeError(w, err)
 		return
 	}
 
@@ -105,19 +239,90 @@ func countHandler(svc *service.QueryService, w http.ResponseWriter, r *http.Requ
 
 	var req service.QueryRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"invalid request body"}`, http.StatusBadRequest)
+		writeError(w, service.ClientError{Msg: "invalid request body"})
 		return
 	}
 
 	resp, err := svc.Count(r.Context(), req)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if service.IsClientError(err) {
-			status = http.StatusBadRequest
-		}
-		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), status)
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+func explainHandler(svc *service.QueryService, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req service.QueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, service.ClientError{Msg: "invalid request body"})
+		return
+	}
+
+	resp, err := svc.Explain(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(resp)
 }
+
+func facetsHandler(svc *service.QueryService, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req service.FacetsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, service.ClientError{Msg: "invalid request body"})
+		return
+	}
+
+	resp, err := svc.Facets(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// errorStatus maps an error to the HTTP status and the machine-readable code
+// reported alongside the message.
+func errorStatus(err error) (int, string) {
+	switch {
+	case service.IsClientError(err), errors.Is(err, storage.ErrBucketRequired):
+		return http.StatusBadRequest, "invalid_request"
+	case errors.Is(err, storage.ErrInvalidPageToken):
+		return http.StatusBadRequest, "invalid_page_token"
+	case errors.Is(err, storage.ErrBucketNotFound):
+		return http.StatusNotFound, "bucket_not_found"
+	case errors.Is(err, storage.ErrObjectNotFound):
+		return http.StatusNotFound, "object_not_found"
+	case errors.Is(err, storage.ErrInvalidRange):
+		return http.StatusRequestedRangeNotSatisfiable, "invalid_range"
+	case errors.Is(err, thumbnail.ErrUnsupportedFormat):
+		return http.StatusUnsupportedMediaType, "unsupported_format"
+	case errors.Is(err, thumbnail.ErrSourceTooLarge):
+		return http.StatusRequestEntityTooLarge, "image_too_large"
+	case errors.Is(err, storage.ErrPermissionDenied):
+		return http.StatusForbidden, "permission_denied"
+	case errors.Is(err, storage.ErrRateLimited):
+		return http.StatusTooManyRequests, "rate_limited"
+	case errors.Is(err, storage.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "timeout"
+	}
+	return http.StatusInternalServerError, "internal"
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status, code := errorStatus(err)
+	var statusErr *storage.StatusError
+	if status == http.StatusTooManyRequests && errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(statusErr.RetryAfter.Seconds()))))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error(), "code": code})
+}
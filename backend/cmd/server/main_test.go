@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/worldlabs/image-grid-viewer/backend/service"
+	"github.com/worldlabs/image-grid-viewer/backend/storage"
+	"github.com/worldlabs/image-grid-viewer/backend/thumbnail"
+)
+
+func TestErrorStatus(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{service.ClientError{Msg: "pattern is required"}, http.StatusBadRequest, "invalid_request"},
+		{storage.ErrBucketRequired, http.StatusBadRequest, "invalid_request"},
+		{&storage.StatusError{StatusCode: http.StatusBadRequest, Code: "InvalidArgument", Argument: "continuation-token"}, http.StatusBadRequest, "invalid_page_token"},
+		{&storage.StatusError{StatusCode: http.StatusBadRequest, Code: "InvalidToken", Message: "security token is invalid"}, http.StatusInternalServerError, "internal"},
+		{&storage.StatusError{StatusCode: http.StatusNotFound}, http.StatusNotFound, "bucket_not_found"},
+		{&storage.StatusError{StatusCode: http.StatusNotFound, Object: "a.jpg"}, http.StatusNotFound, "object_not_found"},
+		{fmt.Errorf("read: %w", storage.ErrInvalidRange), http.StatusRequestedRangeNotSatisfiable, "invalid_range"},
+		{thumbnail.ErrUnsupportedFormat, http.StatusUnsupportedMediaType, "unsupported_format"},
+		{thumbnail.ErrSourceTooLarge, http.StatusRequestEntityTooLarge, "image_too_large"},
+		{&storage.StatusError{StatusCode: http.StatusForbidden}, http.StatusForbidden, "permission_denied"},
+		{&storage.StatusError{StatusCode: http.StatusServiceUnavailable, Code: "SlowDown"}, http.StatusTooManyRequests, "rate_limited"},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout, "timeout"},
+		{fmt.Errorf("boom"), http.StatusInternalServerError, "internal"},
+	}
+	for _, tc := range cases {
+		status, code := errorStatus(tc.err)
+		if status != tc.status || code != tc.code {
+			t.Errorf("%v: got %d %s, want %d %s", tc.err, status, code, tc.status, tc.code)
+		}
+	}
+}
+
+func TestWriteErrorForwardsRetryAfter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, &storage.StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 1500 * time.Millisecond})
+
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Fatalf("unexpected response: %d Retry-After=%q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body["code"] != "rate_limited" {
+		t.Fatalf("unexpected body: %v %v", body, err)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/worldlabs/image-grid-viewer/backend/service"
+	"github.com/worldlabs/image-grid-viewer/backend/storage"
+)
+
+// objectHandler streams an object from any registered backend. A single byte
+// range is forwarded to the backend; multi-range requests and requests with
+// If-Range get the whole object, which RFC 9110 permits. Conditional requests
+// are answered from the object's metadata before its content is opened.
+func objectHandler(svc *service.QueryService, maxAge time.Duration, w http.ResponseWriter, r *http.Request) {
+	uri := r.URL.Query().Get("object")
+	var offset, length int64
+	ranged := false
+	if spec := r.Header.Get("Range"); spec != "" && r.Header.Get("If-Range") == "" {
+		offset, length, ranged = parseRange(spec)
+	}
+
+	var stat *storage.Object
+	if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+		obj, err := svc.StatObject(r.Context(), uri)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		stat = obj
+		if etag := setValidators(w.Header(), *obj, maxAge); notModified(r, etag, obj.Updated) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	reader, err := svc.ReadObject(r.Context(), uri, offset, length)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidRange) {
+			// RFC 9110 asks for the current length alongside a 416.
+			if stat == nil {
+				stat, _ = svc.StatObject(r.Context(), uri)
+			}
+			if stat != nil {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", stat.Size))
+			}
+		}
+		writeError(w, err)
+		return
+	}
+	defer reader.Close()
+
+	obj := reader.Object
+	if ranged && reader.Length == 0 {
+		// No range of an empty object is satisfiable, and "bytes 0--1/0"
+		// is not a valid Content-Range.
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", obj.Size))
+		writeError(w, storage.ErrInvalidRange)
+		return
+	}
+	header := w.Header()
+	contentType := obj.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(obj.Name))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("Accept-Ranges", "bytes")
+	setValidators(header, obj, maxAge)
+
+	status := http.StatusOK
+	if ranged {
+		status = http.StatusPartialContent
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", reader.Offset, reader.Offset+reader.Length-1, obj.Size))
+	}
+	if reader.Length >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(reader.Length, 10))
+	}
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, reader); err != nil {
+		log.Printf("object %s: stream interrupted: %v", obj.Name, err)
+	}
+}
+
+// setValidators sets the caching headers of obj, which a 304 repeats, and
+// returns its entity tag.
+func setValidators(header http.Header, obj storage.Object, maxAge time.Duration) string {
+	// Objects may come from private buckets, so shared caches must not keep them.
+	header.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+	etag := entityTag(obj)
+	if etag != "" {
+		header.Set("ETag", etag)
+	} else {
+		header.Del("ETag")
+	}
+	if !obj.Updated.IsZero() {
+		header.Set("Last-Modified", obj.Updated.UTC().Format(http.TimeFormat))
+	} else {
+		header.Del("Last-Modified")
+	}
+	return etag
+}
+
+// parseRange parses a single "bytes=" range into the offset and length
+// convention of storage.ReadRequest. Anything else, including multiple
+// ranges, reports ok=false so the whole object is served.
+func parseRange(spec string) (offset, length int64, ok bool) {
+	spec, found := strings.CutPrefix(spec, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false
+	}
+	if first == "" {
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || suffix <= 0 {
+			return 0, 0, false
+		}
+		return -suffix, 0, true
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false
+	}
+	if last == "" {
+		return start, 0, true
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, false
+	}
+	return start, end - start + 1, true
+}
+
+// entityTag prefers the backend's ETag and falls back to the generation or
+// content hash, which identify a version just as well.
+func entityTag(obj storage.Object) string {
+	switch {
+	case obj.ETag != "":
+		return strconv.Quote(obj.ETag)
+	case obj.Generation != 0:
+		return strconv.Quote(strconv.FormatInt(obj.Generation, 10))
+	case obj.MD5 != "":
+		return strconv.Quote(obj.MD5)
+	}
+	return ""
+}
+
+// notModified evaluates If-None-Match, falling back to If-Modified-Since as
+// RFC 9110 requires.
+func notModified(r *http.Request, etag string, updated time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || updated.IsZero() {
+		return false
+	}
+	return !updated.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/worldlabs/image-grid-viewer/backend/config"
+	"github.com/worldlabs/image-grid-viewer/backend/service"
+	"github.com/worldlabs/image-grid-viewer/backend/storage"
+)
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		spec           string
+		offset, length int64
+		ok             bool
+	}{
+		{"bytes=0-99", 0, 100, true},
+		{"bytes=100-", 100, 0, true},
+		{"bytes=-50", -50, 0, true},
+		{"bytes= 5-5", 5, 1, true},
+		{"bytes=10-5", 0, 0, false},
+		{"bytes=-0", 0, 0, false},
+		{"bytes=0-1,4-5", 0, 0, false},
+		{"items=0-1", 0, 0, false},
+		{"bytes=abc", 0, 0, false},
+	}
+	for _, tc := range cases {
+		offset, length, ok := parseRange(tc.spec)
+		if offset != tc.offset || length != tc.length || ok != tc.ok {
+			t.Errorf("parseRange(%q) = %d, %d, %v; want %d, %d, %v", tc.spec, offset, length, ok, tc.offset, tc.length, tc.ok)
+		}
+	}
+}
+
+// versionedClient stamps every object with validators, which the memory
+// client does not keep.
+type versionedClient struct {
+	*storage.MemoryClient
+	updated time.Time
+}
+
+func (c versionedClient) Read(ctx context.Context, req storage.ReadRequest) (*storage.ObjectReader, error) {
+	reader, err := c.MemoryClient.Read(ctx, req)
+	if err == nil {
+		reader.Object.ETag, reader.Object.Updated = "v1", c.updated
+	}
+	return reader, err
+}
+
+func (c versionedClient) Stat(ctx context.Context, req storage.StatRequest) (*storage.Object, error) {
+	obj, err := c.MemoryClient.Stat(ctx, req)
+	if err == nil {
+		obj.ETag, obj.Updated = "v1", c.updated
+	}
+	return obj, err
+}
+
+var objectUpdated = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newObjectTestService() *service.QueryService {
+	mem := storage.NewMemoryClient()
+	mem.PutObject("bucket", "img.jpg", []byte("0123456789"))
+	mem.PutObject("bucket", "empty.jpg", nil)
+	registry := storage.NewRegistry()
+	registry.Register(storage.SchemeMemory, versionedClient{mem, objectUpdated})
+	return service.NewQueryService(config.Config{WorkerCount: 1}, registry)
+}
+
+func serveObject(svc *service.QueryService, name string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/object?object=mem://bucket/"+name, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	objectHandler(svc, time.Minute, rec, r)
+	return rec
+}
+func TestObjectHandlerRanges(t *testing.T) {
+	svc := newObjectTestService()
+	cases := []struct {
+		name, object, rangeSpec string
+		status                  int
+		contentRange, body      string
+	}{
+		{"whole", "img.jpg", "", http.StatusOK, "", "0123456789"},
+		{"bounded", "img.jpg", "bytes=2-4", http.StatusPartialContent, "bytes 2-4/10", "234"},
+		{"suffix", "img.jpg", "bytes=-3", http.StatusPartialContent, "bytes 7-9/10", "789"},
+		{"past end", "img.jpg", "bytes=10-", http.StatusRequestedRangeNotSatisfiable, "bytes */10", ""},
+		{"empty object", "empty.jpg", "bytes=0-", http.StatusRequestedRangeNotSatisfiable, "bytes */0", ""},
+		{"empty suffix", "empty.jpg", "bytes=-5", http.StatusRequestedRangeNotSatisfiable, "bytes */0", ""},
+		{"empty unranged", "empty.jpg", "", http.StatusOK, "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			if tc.rangeSpec != "" {
+				header.Set("Range", tc.rangeSpec)
+			}
+			rec := serveObject(svc, tc.object, header)
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.status)
+			}
+			if got := rec.Header().Get("Content-Range"); got != tc.contentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tc.contentRange)
+			}
+			if tc.status != http.StatusRequestedRangeNotSatisfiable && rec.Body.String() != tc.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tc.body)
+			}
+		})
+	}
+}
+
+func TestObjectHandlerNotModified(t *testing.T) {
+	svc := newObjectTestService()
+	cases := []struct {
+		name   string
+		header http.Header
+		status int
+	}{
+		{"matching etag", http.Header{"If-None-Match": {`"other", W/"v1"`}}, http.StatusNotModified},
+		{"stale etag", http.Header{"If-None-Match": {`"v0"`}}, http.StatusOK},
+		{"unchanged since", http.Header{"If-Modified-Since": {objectUpdated.Format(http.TimeFormat)}}, http.StatusNotModified},
+		{"changed since", http.Header{"If-Modified-Since": {objectUpdated.Add(-time.Hour).Format(http.TimeFormat)}}, http.StatusOK},
+		// If-None-Match takes precedence over If-Modified-Since.
+		{"etag wins", http.Header{
+			"If-None-Match":     {`"v0"`},
+			"If-Modified-Since": {objectUpdated.Format(http.TimeFormat)},
+		}, http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serveObject(svc, "img.jpg", tc.header)
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.status)
+			}
+			if got := rec.Header().Get("ETag"); got != `"v1"` {
+				t.Errorf("ETag = %q, want %q", got, `"v1"`)
+			}
+			if tc.status == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Errorf("304 carried a body: %q", rec.Body.String())
+			}
+		})
+	}
+}
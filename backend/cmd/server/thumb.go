@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/worldlabs/image-grid-viewer/backend/service"
+	"github.com/worldlabs/image-grid-viewer/backend/thumbnail"
+)
+
+// thumbHandler serves a downscaled JPEG or PNG preview of an image object.
+// Thumbnails are keyed by the object's version, so they are regenerated when
+// the object changes. Requests without w get defaultWidth.
+func thumbHandler(svc *service.QueryService, thumbs *thumbnail.Thumbnailer, defaultWidth int, maxAge time.Duration, w http.ResponseWriter, r *http.Request) {
+	width := defaultWidth
+	if raw := r.URL.Query().Get("w"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < thumbnail.MinWidth || parsed > thumbnail.MaxWidth {
+			writeError(w, service.ClientError{Msg: fmt.Sprintf("w must be between %d and %d", thumbnail.MinWidth, thumbnail.MaxWidth)})
+			return
+		}
+		width = parsed
+	}
+
+	uri := r.URL.Query().Get("object")
+	obj, err := svc.StatObject(r.Context(), uri)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	version := fmt.Sprintf("%s/%d/%d", entityTag(*obj), obj.Size, obj.Updated.UnixNano())
+	key := thumbnail.Key(uri, version, width)
+	etag := strconv.Quote(key)
+
+	header := w.Header()
+	header.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+	header.Set("ETag", etag)
+	if notModified(r, etag, time.Time{}) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	// The object is only downloaded when the thumbnail is not cached.
+	data, err := thumbs.Get(r.Context(), key, width, func(ctx context.Context) (io.ReadCloser, error) {
+		return svc.ReadObject(ctx, uri, 0, 0)
+	})
+	if err != nil {
+		header.Del("Cache-Control")
+		header.Del("ETag")
+		writeError(w, err)
+		return
+	}
+	header.Set("Content-Type", http.DetectContentType(data))
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -15,35 +17,131 @@ const (
 	defaultWorkerCount    = 8
 	defaultPageSize       = 100
 	defaultPrefetchPages  = 1
+	defaultRetryAttempts  = 4
+	defaultRetryBackoff   = 200 * time.Millisecond
+	defaultRetryMaxDelay  = 5 * time.Second
+	defaultObjectMaxAge   = time.Hour
+	defaultSignedURLTTL   = 15 * time.Minute
+	defaultThumbWidth     = 256
+	defaultListCacheTTL   = 30 * time.Second
+	defaultListCacheSize  = 10000
+	defaultListCacheCap   = 1000000
+	defaultListCacheWait  = time.Minute
+	defaultSortSnapshots  = 16
+	defaultSnapshotSize   = 10000
+	defaultThumbCacheMB   = 512
 	minPageSize           = 25
 	maxPageSize           = 500
 )
 
+// Supported values for STORAGE_BACKEND.
+const (
+	StorageBackendHTTP = "http"
+	StorageBackendSDK  = "sdk"
+)
+
+// Supported values for OBJECT_URLS.
+const (
+	// ObjectURLsProxy serves every object through GET /api/object.
+	ObjectURLsProxy = "proxy"
+	// ObjectURLsPublic hot-links objects of backends with public URLs.
+	ObjectURLsPublic = "public"
+	// ObjectURLsSigned issues signed URLs where the backend can sign them
+	// and proxies everything else.
+	ObjectURLsSigned = "signed"
+)
+
 // Config holds runtime configuration for the backend server.
 type Config struct {
-	Port            string
-	AllowedOrigins  []string
-	Bucket          string
-	RequestTimeout  time.Duration
-	WorkerCount     int
-	DefaultPageSize int
-	MinPageSize     int
-	MaxPageSize     int
-	PrefetchPages   int
+	Port             string
+	AllowedOrigins   []string
+	Bucket           string
+	RequestTimeout   time.Duration
+	WorkerCount      int
+	DefaultPageSize  int
+	MinPageSize      int
+	MaxPageSize      int
+	PrefetchPages    int
+	FSRoot           string
+	RetryAttempts    int
+	RetryBackoff     time.Duration
+	RetryMaxBackoff  time.Duration
+	MemFixture       string
+	ListCacheTTL     time.Duration
+	ListCacheSize    int
+	ListCacheObjects int
+	ListCacheTimeout time.Duration
+	SortSnapshots    int
+	SortSnapshotSize int
+	ObjectURLs       string
+	ObjectMaxAge     time.Duration
+	SignedURLExpiry  time.Duration
+
+	ThumbWidth       int
+	ThumbCacheDir    string
+	ThumbCacheMaxMB  int
+	ThumbConcurrency int
+
+	StorageBackend     string
+	GCSCredentialsFile string
+	GCSEmulatorHost    string
+	GCSBaseURL         string
+	GCSUserProject     string
+	GCSSigningKeyFile  string
+
+	S3Endpoint        string
+	S3Region          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3SessionToken    string
+	S3PathStyle       bool
 }
 
 // Load reads configuration from environment variables with sensible defaults.
 func Load() Config {
 	cfg := Config{
-		Port:            getEnv("PORT", defaultPort),
-		AllowedOrigins:  splitAndTrim(getEnv("ALLOWED_ORIGINS", defaultOrigins)),
-		Bucket:          getEnv("GCS_BUCKET", defaultBucket),
-		RequestTimeout:  getDurationEnv("REQUEST_TIMEOUT", defaultRequestTimeout),
-		WorkerCount:     getIntEnv("WORKER_COUNT", defaultWorkerCount),
-		DefaultPageSize: getIntEnv("DEFAULT_PAGE_SIZE", defaultPageSize),
-		MinPageSize:     getIntEnv("MIN_PAGE_SIZE", minPageSize),
-		MaxPageSize:     getIntEnv("MAX_PAGE_SIZE", maxPageSize),
-		PrefetchPages:   getIntEnv("PREFETCH_PAGES", defaultPrefetchPages),
+		Port:             getEnv("PORT", defaultPort),
+		AllowedOrigins:   splitAndTrim(getEnv("ALLOWED_ORIGINS", defaultOrigins)),
+		Bucket:           getEnv("GCS_BUCKET", defaultBucket),
+		RequestTimeout:   getDurationEnv("REQUEST_TIMEOUT", defaultRequestTimeout),
+		WorkerCount:      getIntEnv("WORKER_COUNT", defaultWorkerCount),
+		DefaultPageSize:  getIntEnv("DEFAULT_PAGE_SIZE", defaultPageSize),
+		MinPageSize:      getIntEnv("MIN_PAGE_SIZE", minPageSize),
+		MaxPageSize:      getIntEnv("MAX_PAGE_SIZE", maxPageSize),
+		PrefetchPages:    getIntEnv("PREFETCH_PAGES", defaultPrefetchPages),
+		FSRoot:           os.Getenv("FS_ROOT"),
+		RetryAttempts:    getIntEnv("RETRY_MAX_ATTEMPTS", defaultRetryAttempts),
+		RetryBackoff:     getDurationEnv("RETRY_INITIAL_BACKOFF", defaultRetryBackoff),
+		RetryMaxBackoff:  getDurationEnv("RETRY_MAX_BACKOFF", defaultRetryMaxDelay),
+		MemFixture:       os.Getenv("MEM_FIXTURE"),
+		ListCacheTTL:     getDurationEnv("LIST_CACHE_TTL", defaultListCacheTTL),
+		ListCacheSize:    getIntEnv("LIST_CACHE_MAX_ENTRIES", defaultListCacheSize),
+		ListCacheObjects: getIntEnv("LIST_CACHE_MAX_OBJECTS", defaultListCacheCap),
+		ListCacheTimeout: getDurationEnv("LIST_CACHE_TIMEOUT", defaultListCacheWait),
+		SortSnapshots:    getIntEnv("SORT_SNAPSHOTS", defaultSortSnapshots),
+		SortSnapshotSize: getIntEnv("SORT_SNAPSHOT_SIZE", defaultSnapshotSize),
+		ObjectURLs:       strings.ToLower(getEnv("OBJECT_URLS", ObjectURLsProxy)),
+		ObjectMaxAge:     getDurationEnv("OBJECT_CACHE_MAX_AGE", defaultObjectMaxAge),
+		SignedURLExpiry:  getDurationEnv("SIGNED_URL_EXPIRY", defaultSignedURLTTL),
+
+		ThumbWidth:       getIntEnv("THUMB_WIDTH", defaultThumbWidth),
+		ThumbCacheDir:    getEnv("THUMB_CACHE_DIR", filepath.Join(os.TempDir(), "image-grid-viewer-thumbs")),
+		ThumbCacheMaxMB:  getIntEnv("THUMB_CACHE_MAX_MB", defaultThumbCacheMB),
+		ThumbConcurrency: getIntEnv("THUMB_CONCURRENCY", runtime.NumCPU()),
+
+		StorageBackend:     strings.ToLower(getEnv("STORAGE_BACKEND", StorageBackendHTTP)),
+		GCSCredentialsFile: os.Getenv("GCS_CREDENTIALS_FILE"),
+		GCSEmulatorHost:    os.Getenv("STORAGE_EMULATOR_HOST"),
+		GCSBaseURL:         os.Getenv("GCS_BASE_URL"),
+		GCSUserProject:     os.Getenv("GCS_USER_PROJECT"),
+		GCSSigningKeyFile:  getEnv("GCS_SIGNING_KEY_FILE", os.Getenv("GCS_CREDENTIALS_FILE")),
+
+		S3Endpoint:        os.Getenv("S3_ENDPOINT"),
+		S3Region:          getEnv("S3_REGION", os.Getenv("AWS_REGION")),
+		S3AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		S3SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		S3SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		S3PathStyle:       getBoolEnv("S3_FORCE_PATH_STYLE", false),
 	}
 
 	if cfg.MinPageSize < 1 {
@@ -61,6 +159,15 @@ func Load() Config {
 	if cfg.PrefetchPages < 0 {
 		cfg.PrefetchPages = 0
 	}
+	if cfg.RetryAttempts < 1 {
+		cfg.RetryAttempts = 1
+	}
+	if cfg.ThumbWidth < 1 {
+		cfg.ThumbWidth = defaultThumbWidth
+	}
+	if cfg.ThumbConcurrency < 1 {
+		cfg.ThumbConcurrency = 1
+	}
 
 	return cfg
 }
@@ -93,6 +200,15 @@ func getIntEnv(key string, fallback int) int {
 	return fallback
 }
 
+func getBoolEnv(key string, fallback bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
 func getDurationEnv(key string, fallback time.Duration) time.Duration {
 	if v := os.Getenv(key); v != "" {
 		if parsed, err := time.ParseDuration(v); err == nil {
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CaptureKind is the type of a capture's values.
+type CaptureKind string
+
+const (
+	CaptureString CaptureKind = "string"
+	CaptureInt    CaptureKind = "int"
+	CaptureFloat  CaptureKind = "float"
+	CaptureDate   CaptureKind = "date"
+)
+
+const (
+	intRegex = `-?[0-9]+`
+	// recursiveCaptureRegex matches one or more whole path segments.
+	recursiveCaptureRegex = `[^/]+(?:/[^/]+)*`
+	floatRegex            = `-?(?:[0-9]+(?:\.[0-9]*)?|\.[0-9]+)(?:[eE][+-]?[0-9]+)?`
+)
+
+// captureType describes how a percent capture is matched and converted.
+type captureType struct {
+	Kind CaptureKind
+	// Layout is the time layout of date captures.
+	Layout string
+	// Regex matches a single value inside a path segment.
+	Regex string
+	// Values is the complete set of values of an enumerated capture.
+	Values []string
+}
+
+var stringCapture = captureType{Kind: CaptureString, Regex: `[^/]+`}
+
+// parseCaptureType parses the spec after the colon of %name:spec%: one of the
+// types int, float, string or date(<Go time layout>), an enumeration such as
+// {train,val} or {00..99}, or else a regex that constrains a string capture.
+func parseCaptureType(spec string) (captureType, error) {
+	switch spec {
+	case "", string(CaptureString):
+		return stringCapture, nil
+	case string(CaptureInt):
+		return captureType{Kind: CaptureInt, Regex: intRegex}, nil
+	case string(CaptureFloat):
+		return captureType{Kind: CaptureFloat, Regex: floatRegex}, nil
+	}
+	if strings.HasPrefix(spec, "{") && strings.HasSuffix(spec, "}") {
+		enum, ok, err := parseEnumeration(spec[1 : len(spec)-1])
+		if err != nil {
+			return captureType{}, err
+		}
+		if !ok {
+			return captureType{}, fmt.Errorf("invalid enumeration %s", spec)
+		}
+		kind := CaptureString
+		if enum.Numeric {
+			kind = CaptureInt
+		}
+		return captureType{Kind: kind, Regex: enum.regex(), Values: enum.Values}, nil
+	}
+	if strings.HasPrefix(spec, "date(") && strings.HasSuffix(spec, ")") {
+		layout := spec[len("date(") : len(spec)-1]
+		if layout == "" {
+			return captureType{}, fmt.Errorf("empty date layout")
+		}
+		if strings.Contains(layout, "/") {
+			return captureType{}, fmt.Errorf("date layout %q spans path segments", layout)
+		}
+		return captureType{Kind: CaptureDate, Layout: layout, Regex: dateLayoutRegex(layout)}, nil
+	}
+	return constraintCapture(spec)
+}
+
+// constraintCapture validates an inline regex constraint such as
+// %class:[0-9]{4}%. It has to stay within one path segment and may not
+// declare named groups of its own or use anchors, which would apply to the
+// whole name or segment rather than to the capture.
+func constraintCapture(expr string) (captureType, error) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return captureType{}, fmt.Errorf("invalid constraint %q: %w", expr, err)
+	}
+	if canMatchSlash(re) {
+		return captureType{}, fmt.Errorf("constraint %q can match /", expr)
+	}
+	if names := appendCaptureNames(nil, re); len(names) > 0 {
+		return captureType{}, fmt.Errorf("constraint %q declares capture %s", expr, names[0])
+	}
+	if hasAnchor(re) {
+		return captureType{}, fmt.Errorf("constraint %q may not use anchors such as ^, $ or \\b", expr)
+	}
+	return captureType{Kind: CaptureString, Regex: "(?:" + expr + ")"}, nil
+}
+
+// hasAnchor reports whether re asserts a position anywhere, e.g. with ^, $
+// or \b.
+func hasAnchor(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpBeginText, syntax.OpEndText, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return true
+	}
+	for _, sub := range re.Sub {
+		if hasAnchor(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// convert parses a matched value. ok is false when the value fits the regex
+// but is not valid for the type, e.g. the date 2024-13-45.
+func (ct captureType) convert(value string) (interface{}, bool) {
+	switch ct.Kind {
+	case CaptureInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		return n, err == nil
+	case CaptureFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		return f, err == nil
+	case CaptureDate:
+		t, err := time.Parse(ct.Layout, value)
+		return t, err == nil
+	}
+	return value, true
+}
+
+// layoutChunks maps the elements of a Go time layout to the text they match,
+// longest first so that e.g. "2006" wins over "2".
+var layoutChunks = []struct {
+	chunk string
+	regex string
+}{
+	{"January", `[A-Za-z]+`},
+	{"Monday", `[A-Za-z]+`},
+	{"Z07:00", `(?:Z|[+-][0-9]{2}:[0-9]{2})`},
+	{"-07:00", `[+-][0-9]{2}:[0-9]{2}`},
+	{"Z0700", `(?:Z|[+-][0-9]{4})`},
+	{"-0700", `[+-][0-9]{4}`},
+	{"2006", `[0-9]{4}`},
+	{"Jan", `[A-Za-z]{3}`},
+	{"Mon", `[A-Za-z]{3}`},
+	{"MST", `[A-Za-z0-9+-]+`},
+	{"Z07", `(?:Z|[+-][0-9]{2})`},
+	{"-07", `[+-][0-9]{2}`},
+	{"002", `[0-9]{3}`},
+	{"_2006", `_[0-9]{4}`},
+	{"__2", `[ 0-9]{3}`},
+	{"_2", `[ 0-9][0-9]`},
+	{"01", `[0-9]{2}`},
+	{"02", `[0-9]{2}`},
+	{"03", `[0-9]{2}`},
+	{"04", `[0-9]{2}`},
+	{"05", `[0-9]{2}`},
+	{"06", `[0-9]{2}`},
+	{"15", `[0-9]{2}`},
+	{"PM", `[AP]M`},
+	{"pm", `[ap]m`},
+	{"1", `[0-9]{1,2}`},
+	{"2", `[0-9]{1,2}`},
+	{"3", `[0-9]{1,2}`},
+	{"4", `[0-9]{1,2}`},
+	{"5", `[0-9]{1,2}`},
+}
+
+// fractionChunk matches fractional seconds, which like in package time must
+// not be followed by another digit.
+var fractionChunk = regexp.MustCompile(`^[.,](0+|9+)(?:[^0-9]|$)`)
+
+// dateLayoutRegex translates a time layout into a regex that matches the
+// values it formats. Anything that is not a layout element is literal.
+func dateLayoutRegex(layout string) string {
+	var builder strings.Builder
+	for i := 0; i < len(layout); {
+		if m := fractionChunk.FindStringSubmatch(layout[i:]); m != nil {
+			if m[1][0] == '0' {
+				fmt.Fprintf(&builder, `[.,][0-9]{%d}`, len(m[1]))
+			} else {
+				builder.WriteString(`(?:[.,][0-9]+)?`)
+			}
+			i += 1 + len(m[1])
+			continue
+		}
+		matched := false
+		for _, c := range layoutChunks {
+			if strings.HasPrefix(layout[i:], c.chunk) {
+				builder.WriteString(c.regex)
+				i += len(c.chunk)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			builder.WriteString(regexp.QuoteMeta(layout[i : i+1]))
+			i++
+		}
+	}
+	return builder.String()
+}
+
+const (
+	// maxEnumerationValues bounds the size of a brace list or range.
+	maxEnumerationValues = 10000
+	// maxSegmentValues bounds how many values an enumerated segment may
+	// expand into before it is listed like any other segment.
+	maxSegmentValues = 256
+)
+
+// enumeration is a brace list such as {train,val} or a numeric range such as
+// {0000..0099}, whose zero padding is kept.
+type enumeration struct {
+	Values  []string
+	Numeric bool
+}
+
+// parseEnumeration parses the text between braces. ok is false when it is
+// neither a list nor a range, in which case the braces are literal.
+func parseEnumeration(body string) (enumeration, bool, error) {
+	if lo, hi, found := strings.Cut(body, ".."); found && isDigits(lo) && isDigits(hi) {
+		enum, err := parseRange(lo, hi)
+		return enum, err == nil, err
+	}
+	if !strings.Contains(body, ",") {
+		return enumeration{}, false, nil
+	}
+	values := strings.Split(body, ",")
+	for _, value := range values {
+		if value == "" {
+			return enumeration{}, false, fmt.Errorf("empty alternative in {%s}", body)
+		}
+		if strings.ContainsAny(value, "/%") {
+			return enumeration{}, false, fmt.Errorf("alternative %q may not contain / or %%", value)
+		}
+	}
+	return enumeration{Values: values}, true, nil
+}
+
+func parseRange(lo, hi string) (enumeration, error) {
+	start, err := strconv.Atoi(lo)
+	if err != nil {
+		return enumeration{}, fmt.Errorf("invalid range start %s", lo)
+	}
+	end, err := strconv.Atoi(hi)
+	if err != nil {
+		return enumeration{}, fmt.Errorf("invalid range end %s", hi)
+	}
+	if start > end {
+		return enumeration{}, fmt.Errorf("range %s..%s is descending", lo, hi)
+	}
+	if end-start >= maxEnumerationValues {
+		return enumeration{}, fmt.Errorf("range %s..%s has more than %d values", lo, hi, maxEnumerationValues)
+	}
+	width := 0
+	if (len(lo) > 1 && lo[0] == '0') || (len(hi) > 1 && hi[0] == '0') {
+		width = max(len(lo), len(hi))
+	}
+	values := make([]string, 0, end-start+1)
+	for n := start; n <= end; n++ {
+		values = append(values, fmt.Sprintf("%0*d", width, n))
+	}
+	return enumeration{Values: values, Numeric: true}, nil
+}
+
+func (e enumeration) regex() string {
+	quoted := make([]string, len(e.Values))
+	for i, value := range e.Values {
+		quoted[i] = regexp.QuoteMeta(value)
+	}
+	return "(?:" + strings.Join(quoted, "|") + ")"
+}
+
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// bindCaptures records the groups of a match (as returned by
+// FindStringSubmatchIndex) in a copy of bound. ok is false when a name is
+// bound to two different values.
+func bindCaptures(text string, loc []int, names []string, bound map[string]string) (map[string]string, bool) {
+	result := bound
+	copied := false
+	for i, name := range names {
+		if i == 0 || name == "" || loc[2*i] < 0 {
+			continue
+		}
+		value := text[loc[2*i]:loc[2*i+1]]
+		if existing, ok := result[name]; ok {
+			if existing != value {
+				return nil, false
+			}
+			continue
+		}
+		if !copied {
+			result = make(map[string]string, len(bound)+1)
+			for k, v := range bound {
+				result[k] = v
+			}
+			copied = true
+		}
+		result[name] = value
+	}
+	return result, true
+}
+
+// bind matches one value of the segment and adds its captures to bound.
+func (seg segment) bind(value string, bound map[string]string) (map[string]string, bool) {
+	loc := seg.Regex.FindStringSubmatchIndex(value)
+	if loc == nil {
+		return nil, false
+	}
+	return bindCaptures(value, loc, seg.groupNames(), bound)
+}
+
+// groupNames returns the capture name of each group of the segment regex.
+func (seg segment) groupNames() []string {
+	if seg.GroupNames != nil {
+		return seg.GroupNames
+	}
+	return seg.Regex.SubexpNames()
+}
+
+// boundPrefixes narrows the segment's literal prefixes with the values of
+// captures that earlier segments already bound, e.g. %exp%_%idx% lists
+// "e1_" once exp is known to be e1.
+func (seg segment) boundPrefixes(bound map[string]string) []string {
+	relevant := false
+	for _, name := range seg.CaptureNames {
+		if _, ok := bound[name]; ok {
+			relevant = true
+		}
+	}
+	if !relevant || seg.RegexBody == "" {
+		return seg.LiteralPrefixes
+	}
+	tree, err := syntax.Parse(seg.RegexBody, syntax.Perl)
+	if err != nil {
+		return seg.LiteralPrefixes
+	}
+	return regexLiteralPrefixes(substituteCaptures(tree, seg.groupNames(), bound))
+}
+
+// substituteCaptures replaces the groups of re whose captures are bound with
+// their literal values. names gives the capture name of each group by index.
+func substituteCaptures(re *syntax.Regexp, names []string, bound map[string]string) *syntax.Regexp {
+	if re.Op == syntax.OpCapture && re.Cap < len(names) {
+		if value, ok := bound[names[re.Cap]]; ok {
+			return &syntax.Regexp{Op: syntax.OpLiteral, Rune: []rune(value)}
+		}
+	}
+	for i, sub := range re.Sub {
+		re.Sub[i] = substituteCaptures(sub, names, bound)
+	}
+	return re
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
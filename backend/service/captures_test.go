@@ -0,0 +1,77 @@
+package service
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestParseCaptureType(t *testing.T) {
+	for spec, want := range map[string]CaptureKind{"": CaptureString, "string": CaptureString, "int": CaptureInt, "float": CaptureFloat, "date(2006-01-02)": CaptureDate} {
+		ct, err := parseCaptureType(spec)
+		if err != nil || ct.Kind != want {
+			t.Fatalf("%q: got %+v, %v", spec, ct, err)
+		}
+	}
+	for _, spec := range []string{"date()", "date(2006/01/02)", "date(2006", "[^_]+", "(?P<x>a)"} {
+		if _, err := parseCaptureType(spec); err == nil {
+			t.Fatalf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestDateLayoutRegexMatchesFormattedValues(t *testing.T) {
+	when := time.Date(2024, time.March, 7, 9, 5, 3, 120000000, time.FixedZone("", -7*3600))
+	for _, layout := range []string{"2006-01-02", "20060102T150405", "Jan_2_2006", "2006-01-02T15:04:05.000Z07:00", "02.01.06 3pm", "January-2"} {
+		re := regexp.MustCompile("^" + dateLayoutRegex(layout) + "$")
+		if value := when.Format(layout); !re.MatchString(value) {
+			t.Fatalf("%s: %s does not match %q", layout, re, value)
+		}
+	}
+}
+
+func TestCaptureTypeConvert(t *testing.T) {
+	date, _ := parseCaptureType("date(2006-01-02)")
+	if v, ok := date.convert("2024-02-29"); !ok || !v.(time.Time).Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected date: %v %v", v, ok)
+	}
+	if _, ok := date.convert("2023-02-29"); ok {
+		t.Fatal("expected invalid date to be rejected")
+	}
+	integer, _ := parseCaptureType("int")
+	if v, ok := integer.convert("007"); !ok || v != int64(7) {
+		t.Fatalf("unexpected int: %v %v", v, ok)
+	}
+	float, _ := parseCaptureType("float")
+	if v, ok := float.convert("0.5"); !ok || v != 0.5 {
+		t.Fatalf("unexpected float: %v %v", v, ok)
+	}
+}
+
+func TestParseEnumeration(t *testing.T) {
+	cases := []struct {
+		body    string
+		want    []string
+		numeric bool
+	}{
+		{"train,val,test", []string{"train", "val", "test"}, false},
+		{"0000..0003", []string{"0000", "0001", "0002", "0003"}, true},
+		{"8..11", []string{"8", "9", "10", "11"}, true},
+		{"08..11", []string{"08", "09", "10", "11"}, true},
+	}
+	for _, tc := range cases {
+		enum, ok, err := parseEnumeration(tc.body)
+		if err != nil || !ok || !reflect.DeepEqual(enum.Values, tc.want) || enum.Numeric != tc.numeric {
+			t.Fatalf("%s: got %+v %v %v", tc.body, enum, ok, err)
+		}
+	}
+	if _, ok, err := parseEnumeration("abc"); ok || err != nil {
+		t.Fatalf("expected literal braces, got %v %v", ok, err)
+	}
+	for _, body := range []string{"a,,b", "a/b,c", "3..1", "0..10000"} {
+		if _, _, err := parseEnumeration(body); err == nil {
+			t.Fatalf("%s: expected error", body)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package service
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // ClientError represents a request validation issue that should surface as HTTP 400.
 type ClientError struct {
@@ -15,6 +18,6 @@ func newClientError(format string, args ...interface{}) error {
 
 // IsClientError reports whether the error results from invalid user input.
 func IsClientError(err error) bool {
-	_, ok := err.(ClientError)
-	return ok
+	var clientErr ClientError
+	return errors.As(err, &clientErr)
 }
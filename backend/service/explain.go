@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// maxExplainJobs bounds how many initial jobs an explanation lists.
+const maxExplainJobs = 100
+
+// manyJobsWarning is the number of initial listings worth warning about.
+const manyJobsWarning = 256
+
+// Explain describes how a pattern would be traversed without listing
+// anything, so users can spot expensive patterns before running them. Filters
+// and where expressions prune the initial jobs as they would in a query.
+func (qs *QueryService) Explain(ctx context.Context, req QueryRequest) (*ExplainResponse, error) {
+	cp, err := qs.compileRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := qs.clientFor(cp.Scheme); err != nil {
+		return nil, err
+	}
+
+	resp := &ExplainResponse{
+		Mode:          cp.Mode,
+		Scheme:        cp.Scheme,
+		Bucket:        cp.Bucket,
+		CaptureNames:  cp.CaptureNames,
+		CaptureTypes:  cp.captureKinds(),
+		LiteralPrefix: cp.LiteralPrefix,
+		Matcher:       cp.Matcher.String(),
+	}
+
+	last := len(cp.Segments) - 1
+	for i, seg := range cp.Segments {
+		literals := seg.LiteralPrefixes
+		if i == last && seg.Values != nil {
+			// The last segment is listed flat even when it is enumerated,
+			// with one listing per value that no other value prefixes.
+			literals = minimizePrefixes(seg.Values)
+		}
+		resp.Segments = append(resp.Segments, ExplainSegment{
+			Index:           i,
+			Raw:             seg.Raw,
+			Regex:           seg.Regex.String(),
+			CaptureNames:    seg.CaptureNames,
+			LiteralPrefixes: literals,
+			Values:          len(seg.Values),
+			Listing:         segmentListing(seg, i == last),
+		})
+	}
+
+	var stats QueryStats
+	jobs := qs.buildInitialJobs(cp, &stats)
+	resp.InitialJobCount = len(jobs)
+	for i, job := range jobs {
+		if i == maxExplainJobs {
+			break
+		}
+		resp.InitialJobs = append(resp.InitialJobs, explainJob(cp, job))
+	}
+
+	resp.Warnings = planWarnings(cp, jobs)
+	return resp, nil
+}
+
+// segmentListing names how the traversal handles a segment.
+func segmentListing(seg segment, last bool) SegmentListing {
+	switch {
+	case last:
+		return ListingFlat
+	case seg.Values != nil:
+		return ListingExpanded
+	case !seg.HasCapture:
+		return ListingLiteral
+	default:
+		return ListingDelimited
+	}
+}
+
+func explainJob(cp *compiledPattern, job listJob) ExplainJob {
+	explained := ExplainJob{
+		Kind:         string(job.Kind),
+		SegmentIndex: job.SegmentIndex,
+		Prefix:       jobListPrefix(cp, job),
+	}
+	if job.Kind == jobKindSegment {
+		explained.Delimiter = "/"
+	}
+	return explained
+}
+
+func planWarnings(cp *compiledPattern, jobs []listJob) []string {
+	warnings := []string{}
+	for _, job := range jobs {
+		if explainJob(cp, job).Prefix != "" {
+			continue
+		}
+		if job.Kind == jobKindSegment {
+			warnings = append(warnings, "capture in first segment: will list every top-level prefix in the bucket")
+		} else {
+			warnings = append(warnings, "no literal prefix: will list the entire bucket")
+		}
+		break
+	}
+
+	if last := len(cp.Segments) - 1; last >= 0 && cp.Segments[last].Recursive {
+		warnings = append(warnings, fmt.Sprintf("segment %d spans directories: everything below it is listed flat and filtered by the full pattern", last))
+	}
+	if len(jobs) > manyJobsWarning {
+		warnings = append(warnings, fmt.Sprintf("pattern expands into %d initial listings", len(jobs)))
+	}
+	return warnings
+}
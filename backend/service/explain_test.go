@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExplainDescribesTraversal(t *testing.T) {
+	qs, mem := newTestService()
+
+	resp, err := qs.Explain(context.Background(), QueryRequest{Pattern: "mem://bucket/runs/{a,b}/run_%exp%/img_%idx:int%.jpg"})
+	if err != nil {
+		t.Fatalf("Explain returned error: %v", err)
+	}
+	want := []SegmentListing{ListingLiteral, ListingExpanded, ListingDelimited, ListingFlat}
+	if len(resp.Segments) != len(want) {
+		t.Fatalf("expected %d segments, got %+v", len(want), resp.Segments)
+	}
+	for i, listing := range want {
+		if resp.Segments[i].Listing != listing {
+			t.Fatalf("segment %d: expected %s, got %s", i, listing, resp.Segments[i].Listing)
+		}
+	}
+	if resp.InitialJobCount != 2 || resp.InitialJobs[0].Prefix != "runs/a/run_" || resp.InitialJobs[0].Delimiter != "/" {
+		t.Fatalf("unexpected initial jobs: %+v", resp.InitialJobs)
+	}
+	if resp.CaptureTypes["idx"] != CaptureInt || len(resp.Warnings) != 0 {
+		t.Fatalf("unexpected explanation: %+v", resp)
+	}
+	if got := len(mem.Requests()); got != 0 {
+		t.Fatalf("explain listed the bucket %d times", got)
+	}
+}
+
+func TestExplainAppliesFiltersAndListsEnumeratedLastSegmentFlat(t *testing.T) {
+	qs, _ := newTestService()
+
+	resp, err := qs.Explain(context.Background(), QueryRequest{
+		Pattern: "mem://bucket/runs/{a,b,c}/img_{1,2,10}",
+		Filters: map[string]CaptureFilter{"$1": {NotIn: []string{"b"}}},
+		Mode:    "glob",
+	})
+	if err != nil {
+		t.Fatalf("Explain returned error: %v", err)
+	}
+	if got := resp.Segments[2]; got.Listing != ListingFlat || !reflect.DeepEqual(got.LiteralPrefixes, []string{"img_1", "img_2"}) {
+		t.Fatalf("expected the last segment to be listed flat by prefix, got %+v", got)
+	}
+	if resp.InitialJobCount != 4 || resp.InitialJobs[2].Prefix != "runs/c/img_1" {
+		t.Fatalf("expected the filtered value to be left out, got %+v", resp.InitialJobs)
+	}
+
+	_, err = qs.Explain(context.Background(), QueryRequest{Pattern: "mem://bucket/%exp%.jpg", Where: "exp >"})
+	if !IsClientError(err) {
+		t.Fatalf("expected client error for an invalid where expression, got %v", err)
+	}
+}
+
+func TestExplainWarnsAboutBucketScans(t *testing.T) {
+	qs, _ := newTestService()
+	cases := map[string]string{
+		"mem://bucket/%exp%/img.jpg":       "capture in first segment",
+		"mem://bucket/img_%idx%.jpg":       "",
+		"mem://bucket/%name%.jpg":          "entire bucket",
+		"mem://bucket/runs/%path**%/x.jpg": "spans directories",
+	}
+	for pattern, want := range cases {
+		resp, err := qs.Explain(context.Background(), QueryRequest{Pattern: pattern})
+		if err != nil {
+			t.Fatalf("%s: Explain returned error: %v", pattern, err)
+		}
+		found := want == "" && len(resp.Warnings) == 0
+		for _, warning := range resp.Warnings {
+			found = found || want != "" && strings.Contains(warning, want)
+		}
+		if !found {
+			t.Fatalf("%s: expected warning %q, got %v", pattern, want, resp.Warnings)
+		}
+	}
+
+	if _, err := qs.Explain(context.Background(), QueryRequest{Pattern: "mem://bucket/%bad"}); !IsClientError(err) {
+		t.Fatalf("expected client error, got %v", err)
+	}
+}
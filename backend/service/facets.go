@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"sort"
+)
+
+// maxFacetValues bounds how many distinct values a facet tracks.
+const maxFacetValues = 1000
+
+// Facets counts the matches of every distinct value of the requested
+// captures, walking the pattern the same way Count does. Like a sorted query
+// it only looks at each match's captures, so no item URLs are built.
+func (qs *QueryService) Facets(ctx context.Context, req FacetsRequest) (*FacetsResponse, error) {
+	cp, err := qs.compileRequest(req.QueryRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	names := req.Captures
+	if len(names) == 0 {
+		names = cp.CaptureNames
+	}
+	for _, name := range names {
+		if !containsString(cp.CaptureNames, name) {
+			return nil, newClientError("unknown capture: %s", name)
+		}
+	}
+
+	client, err := qs.clientFor(cp.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := cp.captureKinds()
+	facets := make([]Facet, len(names))
+	counts := make([]map[interface{}]int, len(names))
+	for i, name := range names {
+		facets[i] = Facet{Capture: name, Type: kinds[name]}
+		counts[i] = map[interface{}]int{}
+	}
+
+	stats, err := qs.walk(ctx, client, facetPattern(cp, names), nil, func(matches []objectMatch) {
+		for _, match := range matches {
+			for i, name := range names {
+				value := match.Captures[name]
+				if _, seen := counts[i][value]; !seen && len(counts[i]) == maxFacetValues {
+					facets[i].Truncated = true
+					continue
+				}
+				counts[i][value]++
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range facets {
+		values := make([]FacetValue, 0, len(counts[i]))
+		for value, count := range counts[i] {
+			values = append(values, FacetValue{Value: value, Count: count})
+		}
+		sort.Slice(values, func(a, b int) bool {
+			return compareCaptures(values[a].Value, values[b].Value) < 0
+		})
+		facets[i].Values = values
+	}
+
+	return &FacetsResponse{
+		Facets: facets,
+		Total:  stats.Matched,
+		Stats:  stats,
+	}, nil
+}
+
+// facetPattern stops the segment-by-segment traversal below the deepest
+// segment binding one of the captures, when the segments after it would list
+// every child anyway. Their objects then come from one flat listing per
+// value instead of a listing per directory, and the full matcher still checks
+// them. A segment with several literal prefixes, such as (train|val), lists
+// only those children, so it keeps the traversal going.
+func facetPattern(cp *compiledPattern, names []string) *compiledPattern {
+	deepest := -1
+	for i, seg := range cp.Segments {
+		for _, name := range seg.CaptureNames {
+			if containsString(names, name) {
+				deepest = i
+			}
+		}
+	}
+	if deepest < 0 || deepest+2 >= len(cp.Segments) {
+		return cp
+	}
+	for _, seg := range cp.Segments[deepest+2:] {
+		if !seg.HasCapture || seg.Values != nil || seg.LiteralPrefix != "" || len(seg.LiteralPrefixes) > 1 {
+			return cp
+		}
+	}
+
+	short := *cp
+	short.Segments = cp.Segments[:deepest+2]
+	return &short
+}
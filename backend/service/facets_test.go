@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestFacetsCountsDistinctValues(t *testing.T) {
+	var objects []string
+	for e := 0; e < 3; e++ {
+		for _, class := range []string{"cat", "dog"} {
+			for i := 0; i <= e; i++ {
+				objects = append(objects, fmt.Sprintf("runs/e%d/%s/img_%d.jpg", e, class, i*5))
+			}
+		}
+	}
+	qs, _ := newTestService(append(objects, "runs/e0/cat/notes.txt")...)
+
+	resp, err := qs.Facets(context.Background(), FacetsRequest{
+		QueryRequest: QueryRequest{Pattern: "mem://bucket/runs/%exp%/%class%/img_%idx:int%.jpg"},
+	})
+	if err != nil {
+		t.Fatalf("Facets returned error: %v", err)
+	}
+	if resp.Total != len(objects) || len(resp.Facets) != 3 {
+		t.Fatalf("expected %d matches over 3 facets, got %d over %d", len(objects), resp.Total, len(resp.Facets))
+	}
+	exp := resp.Facets[0]
+	if exp.Capture != "exp" || len(exp.Values) != 3 || exp.Values[2].Value != "e2" || exp.Values[2].Count != 6 {
+		t.Fatalf("unexpected exp facet: %+v", exp)
+	}
+	idx := resp.Facets[2]
+	if idx.Type != CaptureInt || idx.Values[0].Value != int64(0) || idx.Values[0].Count != 6 || idx.Values[2].Value != int64(10) {
+		t.Fatalf("unexpected idx facet: %+v", idx)
+	}
+
+	if _, err := qs.Facets(context.Background(), FacetsRequest{
+		QueryRequest: QueryRequest{Pattern: "mem://bucket/runs/%exp%/%class%/img_%idx%.jpg"},
+		Captures:     []string{"missing"},
+	}); !IsClientError(err) {
+		t.Fatalf("expected client error for unknown capture, got %v", err)
+	}
+}
+
+func TestFacetsStopAtDeepestNeededSegment(t *testing.T) {
+	var objects []string
+	for e := 0; e < 3; e++ {
+		for c := 0; c < 4; c++ {
+			objects = append(objects, fmt.Sprintf("runs/e%d/c%d/img_0.jpg", e, c), fmt.Sprintf("runs/e%d/c%d/img_1.jpg", e, c))
+		}
+	}
+	qs, _ := newTestService(objects...)
+	req := QueryRequest{Pattern: "mem://bucket/runs/%exp%/%class%/%name%.jpg"}
+
+	resp, err := qs.Facets(context.Background(), FacetsRequest{QueryRequest: req, Captures: []string{"exp"}})
+	if err != nil {
+		t.Fatalf("Facets returned error: %v", err)
+	}
+	count, err := qs.Count(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Count returned error: %v", err)
+	}
+	if resp.Total != count.Total || len(resp.Facets[0].Values) != 3 || resp.Facets[0].Values[0].Count != 8 {
+		t.Fatalf("unexpected facets: %+v", resp.Facets)
+	}
+	// Classes are not listed one by one: only the top-level prefixes are.
+	if resp.Stats.ScannedPrefixes != 3 || count.Stats.ScannedPrefixes != 3+12 {
+		t.Fatalf("expected facets to skip the class listings, scanned %d prefixes (count scanned %d)", resp.Stats.ScannedPrefixes, count.Stats.ScannedPrefixes)
+	}
+
+	cp, err := parsePattern("gs://bucket/runs/%exp%/final/%class%/img_%n%.jpg", ModePercent)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if got := facetPattern(cp, []string{"exp"}); len(got.Segments) != len(cp.Segments) {
+		t.Fatal("expected literal prefixes below the facet to keep the full traversal")
+	}
+}
+
+func TestFacetsKeepAlternativesBelowTheFacet(t *testing.T) {
+	var objects []string
+	for e := 0; e < 2; e++ {
+		for _, split := range []string{"train", "val", "test"} {
+			for i := 0; i < 5; i++ {
+				objects = append(objects, fmt.Sprintf("runs/e%d/c0/%s/img_%d.jpg", e, split, i))
+			}
+		}
+	}
+	qs, _ := newTestService(objects...)
+	req := QueryRequest{Pattern: "mem://bucket/runs/%exp%/%class%/%split:(train|val)%/%name%.jpg"}
+
+	resp, err := qs.Facets(context.Background(), FacetsRequest{QueryRequest: req, Captures: []string{"exp"}})
+	if err != nil {
+		t.Fatalf("Facets returned error: %v", err)
+	}
+	if resp.Total != 20 || len(resp.Facets[0].Values) != 2 {
+		t.Fatalf("unexpected facets: %+v", resp)
+	}
+	// Only the train and val directories are listed, never test.
+	if resp.Stats.ScannedObjects != 20 {
+		t.Fatalf("expected the test split to stay unlisted, scanned %d objects", resp.Stats.ScannedObjects)
+	}
+}
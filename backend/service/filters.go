@@ -0,0 +1,125 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// captureFilters holds a request's filters by capture name.
+type captureFilters map[string]captureFilter
+
+// captureFilter is a filter together with the type of its capture, so that
+// typed values compare by value: 7 equals 007 for an int capture.
+type captureFilter struct {
+	CaptureFilter
+	typ captureType
+}
+
+// newCaptureFilters validates filters against the pattern's captures.
+func newCaptureFilters(cp *compiledPattern, filters map[string]CaptureFilter) (captureFilters, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	fs := make(captureFilters, len(filters))
+	for name, filter := range filters {
+		if !containsString(cp.CaptureNames, name) {
+			return nil, fmt.Errorf("cannot filter on unknown capture: %s", name)
+		}
+		ct, ok := cp.CaptureTypes[name]
+		if !ok {
+			ct = stringCapture
+		}
+		values := append(append([]string(nil), filter.In...), filter.NotIn...)
+		if filter.Equals != nil {
+			values = append(values, *filter.Equals)
+		}
+		for _, value := range values {
+			if _, ok := ct.convert(value); !ok {
+				return nil, fmt.Errorf("capture %s: %q is not a valid %s", name, value, ct.Kind)
+			}
+		}
+		if filter.Min != nil || filter.Max != nil {
+			if ct.Kind == CaptureDate {
+				return nil, fmt.Errorf("capture %s: min and max need a numeric capture", name)
+			}
+			if filter.Min != nil && filter.Max != nil && *filter.Min > *filter.Max {
+				return nil, fmt.Errorf("capture %s: min is greater than max", name)
+			}
+		}
+		fs[name] = captureFilter{CaptureFilter: filter, typ: ct}
+	}
+	return fs, nil
+}
+
+// admits reports whether the captures bound so far pass their filters.
+// Captures that are not bound yet pass, so a subtree is only pruned once
+// the capture deciding it is known.
+func (fs captureFilters) admits(bound map[string]string) bool {
+	for name, filter := range fs {
+		value, ok := bound[name]
+		if ok && !filter.admits(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// key identifies the filters in a cursor, so a cursor cannot be resumed
+// with different filters.
+func (fs captureFilters) key() (string, error) {
+	if len(fs) == 0 {
+		return "", nil
+	}
+	// Maps marshal with sorted keys, so equal filters give equal keys.
+	data, err := json.Marshal(fs)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// admits reports whether a captured value passes every condition of the
+// filter. equals, in and notIn compare values of the capture's type, prefix
+// compares the captured text, and min and max compare it as a number.
+func (f captureFilter) admits(value string) bool {
+	if f.Equals != nil && !f.equal(value, *f.Equals) {
+		return false
+	}
+	if f.In != nil && !f.contains(f.In, value) {
+		return false
+	}
+	if f.contains(f.NotIn, value) {
+		return false
+	}
+	if !strings.HasPrefix(value, f.Prefix) {
+		return false
+	}
+	if f.Min == nil && f.Max == nil {
+		return true
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	return (f.Min == nil || n >= *f.Min) && (f.Max == nil || n <= *f.Max)
+}
+
+func (f captureFilter) contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if f.equal(value, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f captureFilter) equal(value, want string) bool {
+	if f.typ.Kind == CaptureString {
+		return value == want
+	}
+	x, ok := f.typ.convert(value)
+	y, wantOK := f.typ.convert(want)
+	return ok && wantOK && compareCaptures(x, y) == 0
+}
@@ -0,0 +1,85 @@
+package service
+
+import "testing"
+
+func TestCaptureFilterAdmits(t *testing.T) {
+	equals := "e1"
+	low, high := 2.0, 10.0
+	cases := []struct {
+		filter CaptureFilter
+		value  string
+		want   bool
+	}{
+		{CaptureFilter{Equals: &equals}, "e1", true},
+		{CaptureFilter{Equals: &equals}, "e2", false},
+		{CaptureFilter{In: []string{"a", "b"}}, "b", true},
+		{CaptureFilter{In: []string{}}, "a", false},
+		{CaptureFilter{NotIn: []string{"a"}}, "a", false},
+		{CaptureFilter{Prefix: "run_"}, "run_3", true},
+		{CaptureFilter{Prefix: "run_"}, "test_3", false},
+		{CaptureFilter{Min: &low, Max: &high}, "010", true},
+		{CaptureFilter{Min: &low}, "1.5", false},
+		{CaptureFilter{Max: &high}, "x", false},
+	}
+	for i, tc := range cases {
+		filter := captureFilter{CaptureFilter: tc.filter, typ: stringCapture}
+		if got := filter.admits(tc.value); got != tc.want {
+			t.Errorf("case %d: admits(%q) = %v, want %v", i, tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestCaptureFilterComparesTypedValues(t *testing.T) {
+	cp, err := parsePattern("gs://bucket/%day:date(2006-01-02)%/%idx:int%_%score:float%.jpg", ModePercent)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	seven, day, half := "7", "2024-05-01", "0.5"
+	fs, err := newCaptureFilters(cp, map[string]CaptureFilter{
+		"idx":   {Equals: &seven},
+		"day":   {In: []string{day}},
+		"score": {NotIn: []string{half}},
+	})
+	if err != nil {
+		t.Fatalf("newCaptureFilters returned error: %v", err)
+	}
+	cases := []struct {
+		bound map[string]string
+		want  bool
+	}{
+		{map[string]string{"idx": "007"}, true},
+		{map[string]string{"idx": "70"}, false},
+		{map[string]string{"day": "2024-05-01"}, true},
+		{map[string]string{"score": "0.50"}, false},
+		{map[string]string{"score": "0.25"}, true},
+	}
+	for i, tc := range cases {
+		if got := fs.admits(tc.bound); got != tc.want {
+			t.Errorf("case %d: admits(%v) = %v, want %v", i, tc.bound, got, tc.want)
+		}
+	}
+
+	if _, err := newCaptureFilters(cp, map[string]CaptureFilter{"idx": {In: []string{"seven"}}}); err == nil {
+		t.Fatal("expected error for a value that is not an int")
+	}
+}
+
+func TestNewCaptureFiltersValidates(t *testing.T) {
+	cp, err := parsePattern("gs://bucket/%day:date(2006-01-02)%/%idx:int%.jpg", ModePercent)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	low, high := 5.0, 1.0
+	for name, filters := range map[string]map[string]CaptureFilter{
+		"unknown capture": {"missing": {Prefix: "a"}},
+		"date range":      {"day": {Min: &low}},
+		"empty range":     {"idx": {Min: &low, Max: &high}},
+	} {
+		if _, err := newCaptureFilters(cp, filters); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := newCaptureFilters(cp, map[string]CaptureFilter{"idx": {Max: &low}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache is a small, concurrency-safe least recently used cache whose
+// entries optionally expire after ttl.
+type lruCache[K comparable, V any] struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[K]*list.Element
+}
+
+type lruEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// newLRUCache returns a cache of at most size entries, or nil when size is
+// below one; a nil cache keeps nothing. A zero ttl never expires entries.
+func newLRUCache[K comparable, V any](size int, ttl time.Duration) *lruCache[K, V] {
+	if size < 1 {
+		return nil
+	}
+	return &lruCache[K, V]{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: map[K]*list.Element{},
+	}
+}
+
+func (c *lruCache[K, V]) get(key K) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := elem.Value.(*lruEntry[K, V])
+	if c.ttl > 0 && !c.now().Before(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (c *lruCache[K, V]) put(key K, value V) {
+	if c == nil {
+		return
+	}
+	entry := &lruEntry[K, V]{key: key, value: value, expires: c.now().Add(c.ttl)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
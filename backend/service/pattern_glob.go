@@ -0,0 +1,187 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+
+	"github.com/worldlabs/image-grid-viewer/backend/storage"
+)
+
+// parseGlobPattern compiles a shell glob. Every wildcard (*, ?, [...],
+// {...} and **) becomes a positional capture named $1, $2, ... in order.
+func parseGlobPattern(raw string) (*compiledPattern, error) {
+	loc, err := storage.ParseLocation(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSegments := strings.Split(loc.Path, "/")
+	segments := make([]segment, len(rawSegments))
+	var captureNames []string
+	for i, segRaw := range rawSegments {
+		if segRaw == globstar {
+			seg := globstarSegment()
+			seg.CaptureNames = []string{positionalName(len(captureNames) + 1)}
+			segments[i] = seg
+			captureNames = append(captureNames, seg.CaptureNames...)
+			continue
+		}
+		seg, err := parseGlobSegment(segRaw, len(captureNames))
+		if err != nil {
+			return nil, fmt.Errorf("segment %d: %w", i, err)
+		}
+		segments[i] = seg
+		captureNames = append(captureNames, seg.CaptureNames...)
+	}
+
+	cp, err := compileSegments(raw, ModeGlob, loc, segments, captureNames, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Glob groups are unnamed and numbered in order, so group i is $i. The
+	// matcher's own slice must not be renamed.
+	cp.SubexpNames = append([]string(nil), cp.SubexpNames...)
+	for i := 1; i < len(cp.SubexpNames); i++ {
+		cp.SubexpNames[i] = positionalName(i)
+	}
+	return cp, nil
+}
+
+func positionalName(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// parseGlobSegment compiles one path segment of a glob. captured is the
+// number of captures in the segments before it.
+func parseGlobSegment(raw string, captured int) (segment, error) {
+	var builder strings.Builder
+	var captures []string
+	values := newValueSet()
+	capture := func(body string) {
+		builder.WriteString("(" + body + ")")
+		captures = append(captures, positionalName(captured+len(captures)+1))
+	}
+
+	for i := 0; i < len(raw); i++ {
+		switch ch := raw[i]; ch {
+		case '\\':
+			if i+1 < len(raw) {
+				i++
+			}
+		case '*':
+			for i+1 < len(raw) && raw[i+1] == '*' {
+				i++
+			}
+			capture(`[^/]*`)
+			values.unknown()
+			continue
+		case '?':
+			capture(`[^/]`)
+			values.unknown()
+			continue
+		case '[':
+			if end, class, err := globClass(raw[i:]); err != nil {
+				return segment{}, err
+			} else if end > 0 {
+				capture(class)
+				values.unknown()
+				i += end
+				continue
+			}
+		case '{':
+			if end := strings.IndexByte(raw[i+1:], '}'); end != -1 {
+				enum, ok, err := parseEnumeration(raw[i+1 : i+1+end])
+				if err != nil {
+					return segment{}, err
+				}
+				if ok {
+					capture(enum.regex())
+					values.enumerate(enum.Values)
+					i += end + 1
+					continue
+				}
+			}
+		}
+		builder.WriteString(regexp.QuoteMeta(raw[i : i+1]))
+		values.literal(raw[i : i+1])
+	}
+
+	regexBody := builder.String()
+	segRegex, err := regexp.Compile("^" + regexBody + "$")
+	if err != nil {
+		return segment{}, err
+	}
+	tree, err := syntax.Parse(regexBody, syntax.Perl)
+	if err != nil {
+		return segment{}, err
+	}
+	literals := regexLiteralPrefixes(tree)
+	literal := len(captures) == 0
+
+	rawValue := raw
+	if literal {
+		rawValue = literals[0]
+	}
+	return segment{
+		Raw:             rawValue,
+		Regex:           segRegex,
+		RegexBody:       regexBody,
+		CaptureNames:    captures,
+		GroupNames:      append([]string{""}, captures...),
+		LiteralPrefix:   commonPrefix(literals),
+		LiteralPrefixes: literals,
+		HasCapture:      !literal,
+		Values:          values.result(),
+	}, nil
+}
+
+// globClass translates the bracket expression at the start of raw, e.g.
+// [abc], [a-z] or [!0-9], into a regex class. end is the index of the
+// closing bracket, or 0 when there is none and the bracket is literal.
+func globClass(raw string) (end int, class string, err error) {
+	i := 1
+	negate := i < len(raw) && (raw[i] == '!' || raw[i] == '^')
+	if negate {
+		i++
+	}
+	start := i
+	// A leading ] is part of the set.
+	if i < len(raw) && raw[i] == ']' {
+		i++
+	}
+	for i < len(raw) && raw[i] != ']' {
+		i++
+	}
+	if i >= len(raw) {
+		return 0, "", nil
+	}
+
+	var builder strings.Builder
+	builder.WriteByte('[')
+	if negate {
+		builder.WriteByte('^')
+	}
+	for _, r := range raw[start:i] {
+		if strings.ContainsRune(`\[]^`, r) {
+			builder.WriteByte('\\')
+		}
+		builder.WriteRune(r)
+	}
+	if negate {
+		builder.WriteByte('/')
+	}
+	builder.WriteByte(']')
+
+	class = builder.String()
+	re, err := syntax.Parse(class, syntax.Perl)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid character class %s: %w", raw[:i+1], err)
+	}
+	if canMatchSlash(re) {
+		return 0, "", fmt.Errorf("character class %s can match /", raw[:i+1])
+	}
+	return i, class, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGlobPattern(t *testing.T) {
+	cp, err := parsePattern("gs://bucket/runs/{train,val}/img_*_?.[jp]ng", ModeGlob)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if want := []string{"$1", "$2", "$3", "$4"}; !reflect.DeepEqual(cp.CaptureNames, want) {
+		t.Fatalf("capture names mismatch: %v", cp.CaptureNames)
+	}
+	if len(cp.Segments) != 3 || cp.Segments[0].HasCapture || !reflect.DeepEqual(cp.Segments[1].Values, []string{"train", "val"}) {
+		t.Fatalf("unexpected segments: %+v", cp.Segments)
+	}
+	if cp.Segments[2].LiteralPrefix != "img_" {
+		t.Fatalf("literal prefix mismatch: %q", cp.Segments[2].LiteralPrefix)
+	}
+	captures, ok := cp.match("runs/val/img_cat_7.png")
+	if !ok {
+		t.Fatalf("expected match: %s", cp.Matcher)
+	}
+	if want := map[string]interface{}{"$1": "val", "$2": "cat", "$3": "7", "$4": "p"}; !reflect.DeepEqual(captures, want) {
+		t.Fatalf("captures mismatch: %v", captures)
+	}
+	for _, name := range []string{"runs/test/img_cat_7.png", "runs/val/img_cat_77.png", "runs/val/img_a/b_7.png", "runs/val/img_cat_7.gng"} {
+		if cp.Matcher.MatchString(name) {
+			t.Fatalf("%s should not match %s", name, cp.Matcher)
+		}
+	}
+	if names := cp.Matcher.SubexpNames(); names[1] != "" {
+		t.Fatalf("the matcher's own group names were renamed: %q", names)
+	}
+	bound, ok := cp.Segments[2].bind("img_cat_7.png", map[string]string{"$1": "val"})
+	if want := map[string]string{"$1": "val", "$2": "cat", "$3": "7", "$4": "p"}; !ok || !reflect.DeepEqual(bound, want) {
+		t.Fatalf("segment did not bind its positional captures: %v", bound)
+	}
+}
+
+func TestParseGlobPatternGlobstar(t *testing.T) {
+	cp, err := parsePattern(`gs://bucket/runs/**/frame_[!a-z]\*.png`, ModeGlob)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	cases := map[string]string{
+		"runs/frame_1*.png":     "",
+		"runs/a/b/frame_2*.png": "a/b",
+	}
+	for name, want := range cases {
+		captures, ok := cp.match(name)
+		if !ok {
+			t.Fatalf("%s does not match %s", name, cp.Matcher)
+		}
+		if captures["$1"] != want || captures["$2"] == nil {
+			t.Fatalf("%s: unexpected captures %v", name, captures)
+		}
+	}
+	if cp.Matcher.MatchString("runs/frame_x*.png") || cp.Matcher.MatchString("runs/frame_12.png") {
+		t.Fatalf("class or escape mismatch: %s", cp.Matcher)
+	}
+	if len(cp.Segments) != 2 || !cp.Segments[1].Recursive {
+		t.Fatalf("expected traversal to stop at **, got %+v", cp.Segments)
+	}
+
+	if _, err := parsePattern("gs://bucket/a/[+-0].png", ModeGlob); err == nil {
+		t.Fatal("expected a class spanning / to be rejected")
+	}
+}
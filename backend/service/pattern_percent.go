@@ -3,7 +3,10 @@ package service
 import (
 	"fmt"
 	"regexp"
+	"regexp/syntax"
 	"strings"
+
+	"github.com/worldlabs/image-grid-viewer/backend/storage"
 )
 
 var captureNameRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
@@ -12,6 +15,7 @@ var captureNameRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
 type compiledPattern struct {
 	Raw            string
 	Mode           Mode
+	Scheme         string
 	Bucket         string
 	ObjectPattern  string
 	Segments       []segment
@@ -20,21 +24,47 @@ type compiledPattern struct {
 	SubexpNames    []string
 	LiteralPrefix  string
 	LiteralPattern string
+	// CaptureTypes holds the declared type of typed percent captures;
+	// captures without an entry are strings.
+	CaptureTypes map[string]captureType
+	// Filters are the request's capture filters; match rejects objects
+	// they do not admit.
+	Filters captureFilters
+	// Where is the request's compiled where expression, if any.
+	Where *whereProgram
 }
 
 type segment struct {
-	Raw           string
-	Regex         *regexp.Regexp
-	RegexBody     string
-	CaptureNames  []string
+	Raw          string
+	Regex        *regexp.Regexp
+	RegexBody    string
+	CaptureNames []string
+	// GroupNames names the groups of Regex when they are unnamed, as in
+	// globs; index 0 is the whole match. When nil the regex's own names
+	// are used.
+	GroupNames    []string
 	LiteralPrefix string
-	HasCapture    bool
+	// LiteralPrefixes are alternative prefixes that together cover every
+	// value of the segment; each is listed separately.
+	LiteralPrefixes []string
+	HasCapture      bool
+	// Recursive segments span slashes (%name**% or a bare **), so they and
+	// everything after them are matched from a flat listing.
+	Recursive bool
+	// Globstar marks a bare ** segment, which matches zero or more whole
+	// segments.
+	Globstar bool
+	// Values lists every value of a segment built from literals, brace
+	// lists and ranges, which is then expanded instead of listed.
+	Values []string
 }
 
 func parsePattern(raw string, mode Mode) (*compiledPattern, error) {
 	switch mode {
 	case ModeRegex:
 		return parseRegexPattern(raw)
+	case ModeGlob:
+		return parseGlobPattern(raw)
 	case ModePercent:
 		fallthrough
 	default:
@@ -43,41 +73,40 @@ func parsePattern(raw string, mode Mode) (*compiledPattern, error) {
 }
 
 func parsePercentPattern(raw string) (*compiledPattern, error) {
-	if !strings.HasPrefix(raw, "gs://") {
-		return nil, fmt.Errorf("pattern must start with gs://")
-	}
-
-	trimmed := strings.TrimPrefix(raw, "gs://")
-	slash := strings.IndexByte(trimmed, '/')
-	if slash == -1 {
-		return nil, fmt.Errorf("pattern must include bucket and object path")
-	}
-
-	bucket := trimmed[:slash]
-	objectPattern := strings.TrimPrefix(trimmed[slash+1:], "/")
-	if objectPattern == "" {
-		return nil, fmt.Errorf("object pattern is required")
+	loc, err := storage.ParseLocation(raw)
+	if err != nil {
+		return nil, err
 	}
+	objectPattern := loc.Path
 
-	rawSegments := strings.Split(objectPattern, "/")
+	rawSegments := splitPercentSegments(objectPattern)
 	segments := make([]segment, len(rawSegments))
 	var captureNames []string
+	captureTypes := map[string]captureType{}
 	for i, segRaw := range rawSegments {
-		seg, segCaptures, err := parsePercentSegment(segRaw)
+		if segRaw == globstar {
+			segments[i] = globstarSegment()
+			continue
+		}
+		seg, segCaptures, err := parsePercentSegment(segRaw, captureTypes)
 		if err != nil {
 			return nil, fmt.Errorf("segment %d: %w", i, err)
 		}
 		segments[i] = seg
+		// A repeated name constrains its occurrences to be equal.
 		for _, name := range segCaptures {
-			for _, existing := range captureNames {
-				if existing == name {
-					return nil, fmt.Errorf("duplicate capture name: %s", name)
-				}
+			if !containsString(captureNames, name) {
+				captureNames = append(captureNames, name)
 			}
-			captureNames = append(captureNames, name)
 		}
 	}
 
+	return compileSegments(raw, ModePercent, loc, segments, captureNames, captureTypes)
+}
+
+// compileSegments assembles a pattern from its parsed segments, shared by
+// the segment-based modes.
+func compileSegments(raw string, mode Mode, loc storage.Location, segments []segment, captureNames []string, captureTypes map[string]captureType) (*compiledPattern, error) {
 	fullPattern := buildFullRegex(segments)
 	matcher, err := regexp.Compile(fullPattern)
 	if err != nil {
@@ -87,35 +116,150 @@ func parsePercentPattern(raw string) (*compiledPattern, error) {
 	literalPrefix := buildLiteralPrefix(segments)
 	literalPattern := fullPattern
 
+	// Traversal stops at the first recursive segment; the full matcher
+	// checks whatever follows it.
+	for i, seg := range segments {
+		if seg.Recursive {
+			segments = segments[:i+1]
+			break
+		}
+	}
+
 	return &compiledPattern{
 		Raw:            raw,
-		Mode:           ModePercent,
-		Bucket:         bucket,
-		ObjectPattern:  objectPattern,
+		Mode:           mode,
+		Scheme:         loc.Scheme,
+		Bucket:         loc.Bucket,
+		ObjectPattern:  loc.Path,
 		Segments:       segments,
 		CaptureNames:   captureNames,
 		Matcher:        matcher,
 		SubexpNames:    matcher.SubexpNames(),
 		LiteralPrefix:  literalPrefix,
 		LiteralPattern: literalPattern,
+		CaptureTypes:   captureTypes,
 	}, nil
 }
 
-func parsePercentSegment(raw string) (segment, []string, error) {
+const globstar = "**"
+
+func globstarSegment() segment {
+	return segment{
+		Raw:             globstar,
+		Regex:           regexp.MustCompile(`^.*$`),
+		LiteralPrefixes: []string{""},
+		HasCapture:      true,
+		Recursive:       true,
+		Globstar:        true,
+	}
+}
+
+// valueSet enumerates a segment while it consists only of literals and
+// known alternatives such as brace lists and ranges.
+type valueSet struct {
+	// values is nil once the segment can no longer be enumerated.
+	values     []string
+	enumerated bool
+}
+
+func newValueSet() *valueSet {
+	return &valueSet{values: []string{""}}
+}
+
+func (v *valueSet) literal(text string) {
+	v.product([]string{text})
+}
+
+func (v *valueSet) enumerate(options []string) {
+	v.enumerated = true
+	v.product(options)
+}
+
+func (v *valueSet) unknown() {
+	v.values = nil
+}
+
+func (v *valueSet) product(options []string) {
+	if v.values == nil || len(v.values)*len(options) > maxSegmentValues {
+		v.values = nil
+		return
+	}
+	product := make([]string, 0, len(v.values)*len(options))
+	for _, value := range v.values {
+		for _, option := range options {
+			product = append(product, value+option)
+		}
+	}
+	v.values = product
+}
+
+// result returns the segment's distinct values, or nil unless it is fully
+// enumerated.
+func (v *valueSet) result() []string {
+	if !v.enumerated || v.values == nil {
+		return nil
+	}
+	return dedupePrefixes(v.values)
+}
+
+// splitPercentSegments splits a percent pattern on the slashes outside of
+// capture tokens, so constraints like %name:[^/_]+% stay whole.
+func splitPercentSegments(pattern string) []string {
+	var segments []string
+	start := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '%':
+			if i+1 < len(pattern) && pattern[i+1] == '%' {
+				i++
+				continue
+			}
+			if end := strings.IndexByte(pattern[i+1:], '%'); end != -1 {
+				i += end + 1
+			}
+		case '/':
+			segments = append(segments, pattern[start:i])
+			start = i + 1
+		}
+	}
+	return append(segments, pattern[start:])
+}
+
+// parsePercentSegment compiles one path segment. The types of typed captures
+// (%name:type%) are recorded in types.
+func parsePercentSegment(raw string, types map[string]captureType) (segment, []string, error) {
 	var builder strings.Builder
 	var literalPrefix strings.Builder
 	captures := []string{}
 	seenCapture := false
+	recursive := false
+	values := newValueSet()
 
 	i := 0
 	for i < len(raw) {
 		ch := raw[i]
+		if ch == '{' {
+			if end := strings.IndexByte(raw[i+1:], '}'); end != -1 {
+				enum, ok, err := parseEnumeration(raw[i+1 : i+1+end])
+				if err != nil {
+					return segment{}, nil, err
+				}
+				if ok {
+					builder.WriteString(enum.regex())
+					values.enumerate(enum.Values)
+					seenCapture = true
+					i += end + 2
+					continue
+				}
+			}
+		}
 		if ch == '%' {
 			if i+1 < len(raw) && raw[i+1] == '%' {
 				builder.WriteString("%")
 				if !seenCapture {
 					literalPrefix.WriteByte('%')
 				}
+				values.literal("%")
 				i += 2
 				continue
 			}
@@ -123,14 +267,37 @@ func parsePercentSegment(raw string) (segment, []string, error) {
 			if end == -1 {
 				return segment{}, nil, fmt.Errorf("unterminated capture token")
 			}
-			name := raw[i+1 : i+1+end]
+			name, spec, _ := strings.Cut(raw[i+1:i+1+end], ":")
+			name, spans := strings.CutSuffix(name, globstar)
 			if name == "" {
 				return segment{}, nil, fmt.Errorf("empty capture name")
 			}
 			if !captureNameRegex.MatchString(name) {
 				return segment{}, nil, fmt.Errorf("invalid capture name: %s", name)
 			}
-			builder.WriteString(fmt.Sprintf("(?P<%s>[^/]+)", name))
+			ct, err := parseCaptureType(spec)
+			if err != nil {
+				return segment{}, nil, fmt.Errorf("capture %s: %w", name, err)
+			}
+			if spans {
+				if spec != "" {
+					return segment{}, nil, fmt.Errorf("recursive capture %s cannot be typed or constrained", name)
+				}
+				ct.Regex = recursiveCaptureRegex
+				recursive = true
+			}
+			if ct.Kind != CaptureString {
+				if existing, ok := types[name]; ok && (existing.Kind != ct.Kind || existing.Layout != ct.Layout) {
+					return segment{}, nil, fmt.Errorf("capture %s is declared with different types", name)
+				}
+				types[name] = ct
+			}
+			if ct.Values != nil {
+				values.enumerate(ct.Values)
+			} else {
+				values.unknown()
+			}
+			builder.WriteString(fmt.Sprintf("(?P<%s>%s)", name, ct.Regex))
 			captures = append(captures, name)
 			seenCapture = true
 			i += end + 2
@@ -140,6 +307,7 @@ func parsePercentSegment(raw string) (segment, []string, error) {
 		if !seenCapture {
 			literalPrefix.WriteByte(ch)
 		}
+		values.literal(raw[i : i+1])
 		i++
 	}
 
@@ -149,24 +317,100 @@ func parsePercentSegment(raw string) (segment, []string, error) {
 		return segment{}, nil, err
 	}
 
+	hasCapture := len(captures) > 0 || values.enumerated
+	literals := []string{literalPrefix.String()}
+	if hasCapture {
+		// Constraints such as %split:(train|val)% narrow the listing to
+		// their alternatives.
+		tree, err := syntax.Parse(regexBody, syntax.Perl)
+		if err != nil {
+			return segment{}, nil, err
+		}
+		literals = regexLiteralPrefixes(tree)
+	}
+
 	return segment{
-		Raw:           raw,
-		Regex:         segRegex,
-		RegexBody:     regexBody,
-		CaptureNames:  captures,
-		LiteralPrefix: literalPrefix.String(),
-		HasCapture:    len(captures) > 0,
+		Raw:             raw,
+		Regex:           segRegex,
+		RegexBody:       regexBody,
+		CaptureNames:    captures,
+		LiteralPrefix:   commonPrefix(literals),
+		LiteralPrefixes: literals,
+		HasCapture:      hasCapture,
+		Recursive:       recursive,
+		Values:          values.result(),
 	}, captures, nil
 }
 
+// match matches an object name and extracts its typed capture values. It
+// fails when captures sharing a name disagree, when a filter rejects a
+// value, or when a value does not convert to its capture's type.
+func (cp *compiledPattern) match(name string) (map[string]interface{}, bool) {
+	loc := cp.Matcher.FindStringSubmatchIndex(name)
+	if loc == nil {
+		return nil, false
+	}
+	raw, ok := bindCaptures(name, loc, cp.SubexpNames, nil)
+	if !ok || !cp.Filters.admits(raw) {
+		return nil, false
+	}
+	values := make(map[string]interface{}, len(cp.CaptureNames))
+	for _, capture := range cp.CaptureNames {
+		ct, ok := cp.CaptureTypes[capture]
+		if !ok {
+			values[capture] = raw[capture]
+			continue
+		}
+		value, ok := ct.convert(raw[capture])
+		if !ok {
+			return nil, false
+		}
+		values[capture] = value
+	}
+	return values, true
+}
+
+// admitsBound reports whether the captures bound while descending into a
+// prefix can still lead to matches, so rejected prefixes are not listed.
+func (cp *compiledPattern) admitsBound(bound map[string]string) bool {
+	return cp.Filters.admits(bound) && !cp.Where.rejects(bound)
+}
+
+// captureKinds reports the type of every capture, for API clients that sort
+// or filter on capture values.
+func (cp *compiledPattern) captureKinds() map[string]CaptureKind {
+	kinds := make(map[string]CaptureKind, len(cp.CaptureNames))
+	for _, name := range cp.CaptureNames {
+		kinds[name] = CaptureString
+		if ct, ok := cp.CaptureTypes[name]; ok {
+			kinds[name] = ct.Kind
+		}
+	}
+	return kinds
+}
+
 func buildFullRegex(segments []segment) string {
 	var builder strings.Builder
 	builder.WriteString("^")
 	for i, seg := range segments {
-		if i > 0 {
+		if i > 0 && !segments[i-1].Globstar {
 			builder.WriteString("/")
 		}
-		builder.WriteString(seg.RegexBody)
+		// Glob mode's ** captures positionally, so its group is unnamed.
+		capturing := len(seg.CaptureNames) > 0
+		switch {
+		case seg.Globstar && i == len(segments)-1 && capturing:
+			builder.WriteString("(.*)")
+		case seg.Globstar && i == len(segments)-1:
+			builder.WriteString(".*")
+		case seg.Globstar && capturing:
+			builder.WriteString("(?:(" + recursiveCaptureRegex + ")/)?")
+		case seg.Globstar:
+			// Zero or more whole segments, each with its trailing slash.
+			builder.WriteString("(?:[^/]+/)*")
+		default:
+			builder.WriteString(seg.RegexBody)
+		}
 	}
 	builder.WriteString("$")
 	return builder.String()
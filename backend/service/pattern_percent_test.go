@@ -1,6 +1,7 @@
 package service
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -23,10 +24,22 @@ func TestParsePercentPatternSuccess(t *testing.T) {
 	}
 }
 
-func TestParsePercentPatternDuplicateCapture(t *testing.T) {
-	_, err := parsePattern("gs://bucket/%foo%/%foo%.jpg", ModePercent)
-	if err == nil {
-		t.Fatal("expected error")
+func TestParsePercentPatternRepeatedCapture(t *testing.T) {
+	cp, err := parsePattern("gs://bucket/%foo%/%foo%_%idx%.jpg", ModePercent)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if len(cp.CaptureNames) != 2 {
+		t.Fatalf("expected repeated name to be listed once, got %v", cp.CaptureNames)
+	}
+	if captures, ok := cp.match("a/a_1.jpg"); !ok || captures["foo"] != "a" {
+		t.Fatalf("expected equal values to match, got %v %v", captures, ok)
+	}
+	if _, ok := cp.match("a/b_1.jpg"); ok {
+		t.Fatal("expected differing values not to match")
+	}
+	if _, err := parsePattern("gs://bucket/%foo:int%/%foo:float%.jpg", ModePercent); err == nil {
+		t.Fatal("expected conflicting types to be rejected")
 	}
 }
 
@@ -36,3 +49,134 @@ func TestParsePercentPatternInvalidToken(t *testing.T) {
 		t.Fatal("expected validation error")
 	}
 }
+
+func TestParsePercentPatternFileScheme(t *testing.T) {
+	cp, err := parsePattern("file:///data/renders/%exp%/%idx%.png", ModePercent)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if cp.Scheme != "file" || cp.Bucket != "" {
+		t.Fatalf("unexpected location: scheme=%q bucket=%q", cp.Scheme, cp.Bucket)
+	}
+	if cp.LiteralPrefix != "data/renders/" {
+		t.Fatalf("literal prefix mismatch: %q", cp.LiteralPrefix)
+	}
+
+	if _, err := parsePattern("file://host/data/%exp%.png", ModePercent); err == nil {
+		t.Fatal("expected error for file pattern with a host")
+	}
+}
+
+func TestParsePercentPatternTypedCaptures(t *testing.T) {
+	cp, err := parsePattern("gs://bucket/%day:date(2006-01-02)%/img_%idx:int%_%score:float%.jpg", ModePercent)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if !cp.Segments[0].Regex.MatchString("2024-05-01") || cp.Segments[0].Regex.MatchString("latest") {
+		t.Fatalf("date segment regex too loose: %s", cp.Segments[0].Regex)
+	}
+	if !cp.Matcher.MatchString("2024-05-01/img_12_0.75.jpg") || cp.Matcher.MatchString("2024-05-01/img_x_0.75.jpg") {
+		t.Fatalf("typed matcher mismatch: %s", cp.Matcher)
+	}
+	kinds := cp.captureKinds()
+	if kinds["day"] != CaptureDate || kinds["idx"] != CaptureInt || kinds["score"] != CaptureFloat {
+		t.Fatalf("unexpected capture kinds: %v", kinds)
+	}
+	if _, err := parsePattern("gs://bucket/%idx:[0-9%.jpg", ModePercent); err == nil {
+		t.Fatal("expected invalid constraint to be rejected")
+	}
+}
+
+func TestParsePercentPatternConstraints(t *testing.T) {
+	cp, err := parsePattern("gs://bucket/%split:(train|val)%/%class:[0-9]{4}%_%idx:[^/_]+%.jpg", ModePercent)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if len(cp.Segments) != 2 {
+		t.Fatalf("expected 2 segments, got %d", len(cp.Segments))
+	}
+	if got := cp.Segments[0].LiteralPrefixes; len(got) != 2 || got[0] != "train" || got[1] != "val" {
+		t.Fatalf("expected one prefix per alternative, got %q", got)
+	}
+	if !cp.Matcher.MatchString("val/0042_a.jpg") || cp.Matcher.MatchString("test/0042_a.jpg") || cp.Matcher.MatchString("val/42_a.jpg") {
+		t.Fatalf("constraint matcher mismatch: %s", cp.Matcher)
+	}
+	if got := cp.captureKinds(); got["class"] != CaptureString || len(got) != 3 {
+		t.Fatalf("unexpected capture kinds: %v", got)
+	}
+	for _, raw := range []string{"gs://bucket/%path:.+%.jpg", "gs://bucket/%a:(?P<b>x)%.jpg"} {
+		if _, err := parsePattern(raw, ModePercent); err == nil {
+			t.Fatalf("%s: expected error", raw)
+		}
+	}
+}
+
+func TestParsePercentPatternRejectsAnchoredConstraints(t *testing.T) {
+	for _, constraint := range []string{"^[0-9]+", "[0-9]+$", `\bcat`, `x\B`, "(?m:^a)", "a|(b$)", `\Aa`, `a\z`} {
+		raw := "gs://bucket/%class:" + constraint + "%.jpg"
+		_, err := parsePattern(raw, ModePercent)
+		if err == nil || !strings.Contains(err.Error(), "anchors") {
+			t.Fatalf("%s: expected anchor error, got %v", raw, err)
+		}
+	}
+	if _, err := parsePattern("gs://bucket/%class:[0-9^$]+%.jpg", ModePercent); err != nil {
+		t.Fatalf("literal ^ and $ in a class must stay allowed: %v", err)
+	}
+}
+
+func TestParsePercentPatternRecursiveSegments(t *testing.T) {
+	cp, err := parsePattern("gs://bucket/runs/%date%/%path**%/frame_%n:int%.png", ModePercent)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if len(cp.Segments) != 3 || !cp.Segments[2].Recursive {
+		t.Fatalf("expected traversal to stop at the recursive segment, got %d segments", len(cp.Segments))
+	}
+	if !cp.Matcher.MatchString("runs/d1/a/b/c/frame_0001.png") || cp.Matcher.MatchString("runs/d1/frame_0001.png") {
+		t.Fatalf("recursive capture mismatch: %s", cp.Matcher)
+	}
+
+	cp, err = parsePattern("gs://bucket/runs/**/frame_%n%.png", ModePercent)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	for _, name := range []string{"runs/frame_1.png", "runs/a/frame_1.png", "runs/a/b/frame_1.png"} {
+		if !cp.Matcher.MatchString(name) {
+			t.Fatalf("%s does not match %s", name, cp.Matcher)
+		}
+	}
+	if cp.Matcher.MatchString("runsframe_1.png") || len(cp.CaptureNames) != 1 {
+		t.Fatalf("globstar mismatch: %s %v", cp.Matcher, cp.CaptureNames)
+	}
+
+	if _, err := parsePattern("gs://bucket/%path**:int%.png", ModePercent); err == nil {
+		t.Fatal("expected typed recursive capture to be rejected")
+	}
+}
+
+func TestParsePercentPatternEnumerations(t *testing.T) {
+	cp, err := parsePattern("gs://bucket/%split:{train,val}%/{a,b}_frame_%n:{0000..0002}%.png", ModePercent)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if got := cp.Segments[0].Values; len(got) != 2 {
+		t.Fatalf("expected split values, got %q", got)
+	}
+	if got := cp.Segments[1].Values; len(got) != 6 || got[0] != "a_frame_0000.png" {
+		t.Fatalf("expected 6 frame names, got %q", got)
+	}
+	if !cp.Matcher.MatchString("val/b_frame_0002.png") || cp.Matcher.MatchString("val/b_frame_0003.png") || cp.Matcher.MatchString("test/a_frame_0000.png") {
+		t.Fatalf("enumeration matcher mismatch: %s", cp.Matcher)
+	}
+	if kinds := cp.captureKinds(); kinds["split"] != CaptureString || kinds["n"] != CaptureInt {
+		t.Fatalf("unexpected capture kinds: %v", kinds)
+	}
+
+	cp, err = parsePattern("gs://bucket/{x}/%a%_{0..999}.png", ModePercent)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if cp.Segments[0].HasCapture || cp.Segments[1].Values != nil {
+		t.Fatalf("expected literal braces and a listed segment: %+v", cp.Segments)
+	}
+}
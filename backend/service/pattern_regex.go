@@ -3,25 +3,20 @@ package service
 import (
 	"fmt"
 	"regexp"
+	"regexp/syntax"
+	"sort"
 	"strings"
+	"unicode/utf8"
+
+	"github.com/worldlabs/image-grid-viewer/backend/storage"
 )
 
 func parseRegexPattern(raw string) (*compiledPattern, error) {
-	if !strings.HasPrefix(raw, "gs://") {
-		return nil, fmt.Errorf("pattern must start with gs://")
-	}
-
-	trimmed := strings.TrimPrefix(raw, "gs://")
-	slash := strings.IndexByte(trimmed, '/')
-	if slash == -1 {
-		return nil, fmt.Errorf("pattern must include bucket and object path")
-	}
-
-	bucket := trimmed[:slash]
-	objectPattern := strings.TrimPrefix(trimmed[slash+1:], "/")
-	if objectPattern == "" {
-		return nil, fmt.Errorf("object pattern is required")
+	loc, err := storage.ParseLocation(raw)
+	if err != nil {
+		return nil, err
 	}
+	objectPattern := loc.Path
 
 	normPattern := normalizeRegexPattern(objectPattern)
 	anchored := ensureAnchored(normPattern)
@@ -30,13 +25,23 @@ func parseRegexPattern(raw string) (*compiledPattern, error) {
 		return nil, fmt.Errorf("compile regex: %w", err)
 	}
 
-	literalPrefix := regexLiteralPrefix(normPattern)
+	tree, err := syntax.Parse(trimAnchors(normPattern), syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("compile regex: %w", err)
+	}
+	literalPrefix := commonPrefix(regexLiteralPrefixes(tree))
+	segments, err := splitRegexSegments(tree)
+	if err != nil {
+		return nil, fmt.Errorf("compile regex: %w", err)
+	}
 
 	return &compiledPattern{
 		Raw:           raw,
 		Mode:          ModeRegex,
-		Bucket:        bucket,
+		Scheme:        loc.Scheme,
+		Bucket:        loc.Bucket,
 		ObjectPattern: objectPattern,
+		Segments:      segments,
 		Matcher:       matcher,
 		CaptureNames:  collectCaptureNames(matcher.SubexpNames()),
 		SubexpNames:   matcher.SubexpNames(),
@@ -58,33 +63,272 @@ func normalizeRegexPattern(pattern string) string {
 }
 
 func ensureAnchored(pattern string) string {
-	trimmed := strings.TrimPrefix(pattern, "^")
-	trimmed = strings.TrimSuffix(trimmed, "$")
-	return "^" + trimmed + "$"
+	return "^" + trimAnchors(pattern) + "$"
 }
 
-func regexLiteralPrefix(pattern string) string {
-	var builder strings.Builder
-	escaped := false
-	trimmed := strings.TrimPrefix(pattern, "^")
-	trimmed = strings.TrimSuffix(trimmed, "$")
-	for i := 0; i < len(trimmed); i++ {
-		ch := trimmed[i]
-		if escaped {
-			builder.WriteByte(ch)
-			escaped = false
+func trimAnchors(pattern string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(pattern, "^"), "$")
+}
+
+// splitRegexSegments decomposes a regex on its top-level "/" literals so each
+// path segment can be listed with a delimiter, like percent patterns. The
+// first segment that could itself match "/" swallows the rest of the pattern,
+// which is then left to a flat listing and the full matcher.
+func splitRegexSegments(re *syntax.Regexp) ([]segment, error) {
+	pieces := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		pieces = re.Sub
+	}
+
+	var parts [][]*syntax.Regexp
+	var current []*syntax.Regexp
+	spanning := false
+	for _, piece := range pieces {
+		if spanning || piece.Op != syntax.OpLiteral {
+			current = append(current, piece)
+			spanning = spanning || canMatchSlash(piece)
 			continue
 		}
-		if ch == '\\' {
-			escaped = true
+		start := 0
+		for i, r := range piece.Rune {
+			if r != '/' {
+				continue
+			}
+			current = appendLiteral(current, piece.Rune[start:i], piece.Flags)
+			parts = append(parts, current)
+			current = nil
+			start = i + 1
+		}
+		current = appendLiteral(current, piece.Rune[start:], piece.Flags)
+	}
+	parts = append(parts, current)
+
+	segments := make([]segment, len(parts))
+	for i, part := range parts {
+		seg, err := buildRegexSegment(part)
+		if err != nil {
+			return nil, fmt.Errorf("segment %d: %w", i, err)
+		}
+		segments[i] = seg
+	}
+	segments[len(segments)-1].Recursive = spanning
+	return segments, nil
+}
+
+func appendLiteral(nodes []*syntax.Regexp, runes []rune, flags syntax.Flags) []*syntax.Regexp {
+	if len(runes) == 0 {
+		return nodes
+	}
+	return append(nodes, &syntax.Regexp{Op: syntax.OpLiteral, Rune: runes, Flags: flags})
+}
+
+func buildRegexSegment(nodes []*syntax.Regexp) (segment, error) {
+	body := ""
+	if len(nodes) > 0 {
+		body = (&syntax.Regexp{Op: syntax.OpConcat, Sub: nodes}).String()
+	}
+	segRegex, err := regexp.Compile("^" + body + "$")
+	if err != nil {
+		return segment{}, err
+	}
+
+	prefixes, literal := literalPrefixes(&syntax.Regexp{Op: syntax.OpConcat, Sub: nodes})
+	literal = literal && len(prefixes) == 1
+	prefixes = minimizePrefixes(prefixes)
+
+	raw := body
+	if literal {
+		raw = prefixes[0]
+	}
+	var captures []string
+	for _, node := range nodes {
+		captures = appendCaptureNames(captures, node)
+	}
+
+	return segment{
+		Raw:             raw,
+		Regex:           segRegex,
+		RegexBody:       body,
+		CaptureNames:    captures,
+		LiteralPrefix:   commonPrefix(prefixes),
+		LiteralPrefixes: prefixes,
+		// Anything beyond a plain literal has to be listed and matched,
+		// whether or not it captures.
+		HasCapture: !literal,
+	}, nil
+}
+
+func appendCaptureNames(names []string, re *syntax.Regexp) []string {
+	if re.Op == syntax.OpCapture && re.Name != "" {
+		names = append(names, re.Name)
+	}
+	for _, sub := range re.Sub {
+		names = appendCaptureNames(names, sub)
+	}
+	return names
+}
+
+// canMatchSlash reports whether re could consume a "/", i.e. whether it might
+// span several path segments.
+func canMatchSlash(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if r == '/' {
+				return true
+			}
+		}
+		return false
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i] <= '/' && '/' <= re.Rune[i+1] {
+				return true
+			}
+		}
+		return false
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return true
+	}
+	for _, sub := range re.Sub {
+		if canMatchSlash(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxLiteralPrefixes bounds how many alternative prefixes a pattern may fan
+// out into before they are collapsed to their common prefix.
+const maxLiteralPrefixes = 8
+
+// regexLiteralPrefixes returns a prefix-free set of literals such that every
+// string re matches starts with one of them. It is {""} when nothing is known.
+func regexLiteralPrefixes(re *syntax.Regexp) []string {
+	prefixes, _ := literalPrefixes(re)
+	return minimizePrefixes(prefixes)
+}
+
+// literalPrefixes computes the prefix set for re. complete reports that re
+// matches exactly the returned literals, so whatever follows re in a
+// concatenation can extend them.
+func literalPrefixes(re *syntax.Regexp) (prefixes []string, complete bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return []string{""}, false
+		}
+		return []string{string(re.Rune)}, true
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText,
+		syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return []string{""}, true
+	case syntax.OpCapture:
+		return literalPrefixes(re.Sub[0])
+	case syntax.OpCharClass:
+		var runes []string
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				if len(runes) == maxLiteralPrefixes {
+					return []string{""}, false
+				}
+				runes = append(runes, string(r))
+			}
+		}
+		return runes, true
+	case syntax.OpQuest:
+		sub, complete := literalPrefixes(re.Sub[0])
+		return append(sub, ""), complete
+	case syntax.OpPlus:
+		sub, _ := literalPrefixes(re.Sub[0])
+		return sub, false
+	case syntax.OpRepeat:
+		if re.Min == 0 {
+			return []string{""}, false
+		}
+		sub, _ := literalPrefixes(re.Sub[0])
+		return sub, false
+	case syntax.OpConcat:
+		prefixes := []string{""}
+		for _, sub := range re.Sub {
+			next, complete := literalPrefixes(sub)
+			product := crossPrefixes(prefixes, next)
+			if len(product) > maxLiteralPrefixes {
+				if len(prefixes) == 1 {
+					return []string{commonPrefix(product)}, false
+				}
+				return prefixes, false
+			}
+			prefixes = product
+			if !complete {
+				return prefixes, false
+			}
+		}
+		return prefixes, true
+	case syntax.OpAlternate:
+		var union []string
+		complete := true
+		for _, sub := range re.Sub {
+			prefixes, subComplete := literalPrefixes(sub)
+			union = append(union, prefixes...)
+			complete = complete && subComplete
+		}
+		union = dedupePrefixes(union)
+		if len(union) > maxLiteralPrefixes {
+			return []string{commonPrefix(union)}, false
+		}
+		return union, complete
+	}
+	return []string{""}, false
+}
+
+func crossPrefixes(heads, tails []string) []string {
+	product := make([]string, 0, len(heads)*len(tails))
+	for _, head := range heads {
+		for _, tail := range tails {
+			product = append(product, head+tail)
+		}
+	}
+	return dedupePrefixes(product)
+}
+
+func dedupePrefixes(prefixes []string) []string {
+	sort.Strings(prefixes)
+	out := prefixes[:0]
+	for i, prefix := range prefixes {
+		if i == 0 || prefix != prefixes[i-1] {
+			out = append(out, prefix)
+		}
+	}
+	return out
+}
+
+// minimizePrefixes drops every prefix that another one already covers, so
+// listing each of them never returns the same object twice.
+func minimizePrefixes(prefixes []string) []string {
+	prefixes = dedupePrefixes(prefixes)
+	out := prefixes[:0]
+	for _, prefix := range prefixes {
+		if len(out) > 0 && strings.HasPrefix(prefix, out[len(out)-1]) {
 			continue
 		}
-		if strings.ContainsRune(".*+?[](){}|$^", rune(ch)) {
-			break
+		out = append(out, prefix)
+	}
+	return out
+}
+
+func commonPrefix(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
 		}
-		builder.WriteByte(ch)
 	}
-	return builder.String()
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
 }
 
 func collectCaptureNames(subexp []string) []string {
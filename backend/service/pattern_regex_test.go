@@ -1,6 +1,10 @@
 package service
 
-import "testing"
+import (
+	"reflect"
+	"regexp/syntax"
+	"testing"
+)
 
 func TestParseRegexPatternSuccess(t *testing.T) {
 	raw := `gs://bucket/images/(?<class>[0-9]{4})/img_(?<idx>[0-9]{2})\.jpg`
@@ -32,3 +36,117 @@ func TestParseRegexPatternMissingBucket(t *testing.T) {
 		t.Fatal("expected error for missing object path")
 	}
 }
+
+func TestParseRegexPatternS3Scheme(t *testing.T) {
+	cp, err := parsePattern(`s3://renders/(?P<exp>[^/]+)/frame\.png`, ModeRegex)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if cp.Scheme != "s3" || cp.Bucket != "renders" {
+		t.Fatalf("unexpected location: scheme=%q bucket=%q", cp.Scheme, cp.Bucket)
+	}
+}
+
+func TestParseRegexPatternSegments(t *testing.T) {
+	cp, err := parsePattern(`gs://bucket/images/(?<class>[0-9]{4})/img_(?<idx>[0-9]{2})\.jpg`, ModeRegex)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if len(cp.Segments) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(cp.Segments))
+	}
+	if seg := cp.Segments[0]; seg.HasCapture || seg.Raw != "images" {
+		t.Fatalf("expected literal first segment, got %+v", seg)
+	}
+	if seg := cp.Segments[1]; !seg.HasCapture || len(seg.CaptureNames) != 1 || seg.CaptureNames[0] != "class" {
+		t.Fatalf("unexpected capture segment: %+v", seg)
+	}
+	if !cp.Segments[1].Regex.MatchString("0042") || cp.Segments[1].Regex.MatchString("42") {
+		t.Fatalf("segment regex mismatch: %s", cp.Segments[1].Regex)
+	}
+	if seg := cp.Segments[2]; seg.LiteralPrefix != "img_" || !seg.Regex.MatchString("img_07.jpg") {
+		t.Fatalf("unexpected final segment: %+v", seg)
+	}
+}
+
+func TestParseRegexPatternSpanningSegment(t *testing.T) {
+	cp, err := parsePattern(`gs://bucket/renders/run_(?P<path>.+)/frame\.png`, ModeRegex)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if len(cp.Segments) != 2 {
+		t.Fatalf("expected the spanning capture to end segmentation, got %d segments", len(cp.Segments))
+	}
+	if seg := cp.Segments[1]; seg.LiteralPrefix != "run_" || !seg.Regex.MatchString("run_a/b/frame.png") {
+		t.Fatalf("unexpected tail segment: %+v", seg)
+	}
+}
+
+func TestParseRegexPatternGroupedAlternation(t *testing.T) {
+	cp, err := parsePattern(`gs://bucket/a/(?:x|y)z/b\.jpg`, ModeRegex)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if len(cp.Segments) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(cp.Segments))
+	}
+	seg := cp.Segments[1]
+	if !seg.Regex.MatchString("xz") || !seg.Regex.MatchString("yz") || seg.Regex.MatchString("x") {
+		t.Fatalf("alternation lost its grouping: %s", seg.Regex)
+	}
+}
+
+func TestRegexLiteralPrefixes(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    []string
+	}{
+		{`img_a?\.jpg`, []string{"img_.jpg", "img_a.jpg"}},
+		{`(foo|bar)x`, []string{"barx", "foox"}},
+		{`(train|val)/.*\.png`, []string{"train/", "val/"}},
+		{`images/[0-9]{4}/x`, []string{"images/"}},
+		{`\d+x`, []string{""}},
+		{`(?i)abc`, []string{""}},
+		{`a(b|c)*d`, []string{"a"}},
+		{`ab|abc`, []string{"ab"}},
+		{`xa(1|2|3|4|5|6|7|8|9)`, []string{"xa"}},
+	}
+	for _, tc := range cases {
+		re, err := syntax.Parse(tc.pattern, syntax.Perl)
+		if err != nil {
+			t.Fatalf("parse %s: %v", tc.pattern, err)
+		}
+		if got := regexLiteralPrefixes(re); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestParseRegexPatternOptionalLiteral(t *testing.T) {
+	cp, err := parsePattern(`gs://bucket/img_a?\.jpg`, ModeRegex)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if cp.LiteralPrefix != "img_" {
+		t.Fatalf("literal prefix mismatch: %q", cp.LiteralPrefix)
+	}
+}
+
+func TestParseRegexPatternRepeatedCapture(t *testing.T) {
+	cp, err := parsePattern(`gs://bucket/(?P<exp>[^/]+)/renders/(?P<exp>[^/_]+)_(?P<idx>\d+)\.png`, ModeRegex)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if len(cp.CaptureNames) != 2 {
+		t.Fatalf("expected 2 capture names, got %v", cp.CaptureNames)
+	}
+	if _, ok := cp.match("e1/renders/e1_3.png"); !ok {
+		t.Fatal("expected equal values to match")
+	}
+	if _, ok := cp.match("e1/renders/e2_3.png"); ok {
+		t.Fatal("expected differing values not to match")
+	}
+	if got := cp.Segments[2].boundPrefixes(map[string]string{"exp": "e1"}); len(got) != 1 || got[0] != "e1_" {
+		t.Fatalf("expected bound prefix e1_, got %q", got)
+	}
+}
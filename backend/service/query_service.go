@@ -5,6 +5,9 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -24,19 +27,45 @@ type listJob struct {
 	SegmentIndex int     `json:"segmentIndex"`
 	Prefix       string  `json:"prefix"`
 	PageToken    string  `json:"pageToken,omitempty"`
+	// Literal is the segment prefix alternative this job lists.
+	Literal string `json:"literal,omitempty"`
+	// Bound holds the captures matched by the segments above Prefix.
+	Bound map[string]string `json:"bound,omitempty"`
+}
+
+// listPrefix is the prefix a segment or objects job lists. The segment's
+// literal prefix narrows the listing within the parent directory, e.g.
+// run_%exp% lists "<base>/run_".
+func (j listJob) listPrefix() string {
+	return ensureTrailingSlash(j.Prefix) + j.Literal
 }
 
 type cursorState struct {
-	Pattern string     `json:"pattern"`
-	Mode    Mode       `json:"mode"`
-	Bucket  string     `json:"bucket"`
-	Jobs    []listJob  `json:"jobs"`
-	Stats   QueryStats `json:"stats"`
+	Pattern string      `json:"pattern"`
+	Mode    Mode        `json:"mode"`
+	Bucket  string      `json:"bucket"`
+	Jobs    []listJob   `json:"jobs"`
+	Pending []QueryItem `json:"pending,omitempty"`
+	// Stats add up the listings of every page so far.
+	Stats QueryStats `json:"stats"`
+	// Sort and After resume a sorted query, which returns the matches
+	// ordered after the object After. Snapshot names the sortSnapshot that
+	// serves the next page; without it the page walks the pattern again.
+	Sort     []SortKey `json:"sort,omitempty"`
+	After    string    `json:"after,omitempty"`
+	Snapshot string    `json:"snapshot,omitempty"`
+	// Filters and Where are the filters the cursor was created with.
+	Filters string `json:"filters,omitempty"`
+	Where   string `json:"where,omitempty"`
 }
 
 type QueryService struct {
-	cfg     config.Config
-	storage storage.Client
+	cfg       config.Config
+	registry  *storage.Registry
+	snapshots *lruCache[string, *sortSnapshot]
+	// wheres holds compiled where expressions by whereKey, so that the
+	// pages of a query compile theirs once.
+	wheres *lruCache[whereKey, *whereProgram]
 }
 
 type jobTask struct {
@@ -44,27 +73,33 @@ type jobTask struct {
 	limit int
 }
 
+// objectMatch is a listed object that matched the pattern. Items with URLs
+// are only built for the matches a page returns.
+type objectMatch struct {
+	Object   storage.Object
+	Captures map[string]interface{}
+}
+
 type jobOutcome struct {
-	items   []QueryItem
+	matches []objectMatch
 	newJobs []listJob
 	stats   QueryStats
 	err     error
 }
 
-func NewQueryService(cfg config.Config, storage storage.Client) *QueryService {
+// NewQueryService builds a service that lists objects through the client
+// registered for each pattern's URL scheme.
+func NewQueryService(cfg config.Config, registry *storage.Registry) *QueryService {
 	return &QueryService{
-		cfg:     cfg,
-		storage: storage,
+		cfg:       cfg,
+		registry:  registry,
+		snapshots: newLRUCache[string, *sortSnapshot](cfg.SortSnapshots, sortSnapshotTTL),
+		wheres:    newLRUCache[whereKey, *whereProgram](whereCacheSize, 0),
 	}
 }
 
 func (qs *QueryService) Query(ctx context.Context, req QueryRequest) (*QueryResponse, error) {
-	pattern := strings.TrimSpace(req.Pattern)
-	if pattern == "" {
-		return nil, newClientError("pattern is required")
-	}
-
-	mode, err := ParseMode(req.Mode)
+	cp, err := qs.compileRequest(req)
 	if err != nil {
 		return nil, err
 	}
@@ -80,114 +115,80 @@ func (qs *QueryService) Query(ctx context.Context, req QueryRequest) (*QueryResp
 		pageSize = qs.cfg.MaxPageSize
 	}
 
-	cp, err := parsePattern(pattern, mode)
+	client, err := qs.clientFor(cp.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	sorter, err := newResultSorter(cp, req.Sort)
 	if err != nil {
 		return nil, newClientError("%v", err)
 	}
 
-	var jobs []listJob
-	stats := QueryStats{}
+	filterKey, err := cp.Filters.key()
+	if err != nil {
+		return nil, err
+	}
+
+	var state *cursorState
 	if req.Cursor != "" {
-		state, err := qs.decodeCursor(req.Cursor)
+		state, err = qs.decodeCursor(req.Cursor)
 		if err != nil {
 			return nil, newClientError("invalid cursor")
 		}
 		if state.Pattern != cp.Raw || state.Mode != cp.Mode || state.Bucket != cp.Bucket {
 			return nil, newClientError("cursor does not match current pattern")
 		}
+		if !slices.Equal(state.Sort, req.Sort) {
+			return nil, newClientError("cursor does not match current sort")
+		}
+		if state.Filters != filterKey || state.Where != strings.TrimSpace(req.Where) {
+			return nil, newClientError("cursor does not match current filters")
+		}
+	}
+
+	if sorter != nil {
+		return qs.querySorted(ctx, client, cp, sorter, req, pageSize, state)
+	}
+
+	var jobs []listJob
+	var pending []QueryItem
+	stats := QueryStats{}
+	if state != nil {
 		jobs = state.Jobs
+		pending = state.Pending
 		stats = state.Stats
 	} else {
-		jobs = qs.buildInitialJobs(cp)
+		jobs = qs.buildInitialJobs(cp, &stats)
 	}
 
+	// Matches left over from the previous page come first.
 	items := make([]QueryItem, 0, pageSize)
-
-	workerCount := qs.cfg.WorkerCount
-	if workerCount < 1 {
-		workerCount = 1
-	}
+	carried := min(len(pending), pageSize)
+	items = append(items, pending[:carried]...)
+	pending = pending[carried:]
 
 	objectBatchSize := qs.objectBatchSize(pageSize)
 
 	for len(items) < pageSize && len(jobs) > 0 {
-		remaining := pageSize - len(items)
-		if remaining <= 0 {
-			break
-		}
-
-		batchSize := workerCount
-		if len(jobs) < batchSize {
-			batchSize = len(jobs)
-		}
-
-		finalBatch := make([]jobTask, 0, batchSize)
-		for len(finalBatch) < batchSize && len(jobs) > 0 {
-			job := jobs[0]
-			jobs = jobs[1:]
-			task := jobTask{job: job}
-			if job.Kind == jobKindObjects {
-				task.limit = objectBatchSize
-			}
-			finalBatch = append(finalBatch, task)
-		}
-
-		if len(finalBatch) == 0 {
-			break
-		}
-
-		outcomes := make(chan jobOutcome, len(finalBatch))
-		var wg sync.WaitGroup
-
-		for _, task := range finalBatch {
-			wg.Add(1)
-			go func(task jobTask) {
-				defer wg.Done()
-				localStats := QueryStats{}
-				switch task.job.Kind {
-				case jobKindSegment:
-					additionalJobs, err := qs.processSegmentJob(ctx, cp, task.job, &localStats)
-					outcomes <- jobOutcome{
-						newJobs: additionalJobs,
-						stats:   localStats,
-						err:     err,
-					}
-				case jobKindObjects:
-					newItems, nextJobs, err := qs.processObjectsJob(ctx, cp, task.job, task.limit, &localStats, true)
-					outcomes <- jobOutcome{
-						items:   newItems,
-						newJobs: nextJobs,
-						stats:   localStats,
-						err:     err,
-					}
-				default:
-					outcomes <- jobOutcome{
-						err: fmt.Errorf("unknown job kind: %s", task.job.Kind),
-					}
-				}
-			}(task)
-		}
-
-		wg.Wait()
-		close(outcomes)
+		var batch []jobTask
+		batch, jobs = qs.nextBatch(jobs, objectBatchSize)
 
-		for outcome := range outcomes {
+		for _, outcome := range qs.runBatch(ctx, client, cp, batch, true) {
 			if outcome.err != nil {
 				return nil, outcome.err
 			}
 
-			stats.ScannedPrefixes += outcome.stats.ScannedPrefixes
-			stats.ScannedObjects += outcome.stats.ScannedObjects
-			stats.Matched += outcome.stats.Matched
-
-			if len(outcome.items) > 0 {
-				available := pageSize - len(items)
-				if available > 0 {
-					if len(outcome.items) > available {
-						items = append(items, outcome.items[:available]...)
-					} else {
-						items = append(items, outcome.items...)
-					}
+			stats.add(outcome.stats)
+
+			// Parallel jobs can overshoot the page; their listings have
+			// already moved on, so the surplus is carried in the cursor.
+			for _, match := range outcome.matches {
+				item := qs.newItem(client, cp, match, req.IncludeMetadata)
+				if len(items) < pageSize {
+					items = append(items, item)
+				} else {
+					pending = append(pending, item)
 				}
 			}
 
@@ -198,13 +199,16 @@ func (qs *QueryService) Query(ctx context.Context, req QueryRequest) (*QueryResp
 	}
 
 	var nextCursor *string
-	if len(jobs) > 0 {
+	if len(jobs) > 0 || len(pending) > 0 {
 		cursorValue, err := qs.encodeCursor(cursorState{
 			Pattern: cp.Raw,
 			Mode:    cp.Mode,
 			Bucket:  cp.Bucket,
 			Jobs:    jobs,
+			Pending: pending,
 			Stats:   stats,
+			Filters: filterKey,
+			Where:   strings.TrimSpace(req.Where),
 		})
 		if err != nil {
 			return nil, err
@@ -214,6 +218,7 @@ func (qs *QueryService) Query(ctx context.Context, req QueryRequest) (*QueryResp
 
 	return &QueryResponse{
 		CaptureNames: cp.CaptureNames,
+		CaptureTypes: cp.captureKinds(),
 		Items:        items,
 		NextCursor:   nextCursor,
 		Stats:        stats,
@@ -221,6 +226,30 @@ func (qs *QueryService) Query(ctx context.Context, req QueryRequest) (*QueryResp
 }
 
 func (qs *QueryService) Count(ctx context.Context, req QueryRequest) (*CountResponse, error) {
+	cp, err := qs.compileRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := qs.clientFor(cp.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := qs.walk(ctx, client, cp, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CountResponse{
+		Total: stats.Matched,
+		Stats: stats,
+	}, nil
+}
+
+// compileRequest parses a request's pattern and attaches its filters and
+// where expression.
+func (qs *QueryService) compileRequest(req QueryRequest) (*compiledPattern, error) {
 	pattern := strings.TrimSpace(req.Pattern)
 	if pattern == "" {
 		return nil, newClientError("pattern is required")
@@ -236,85 +265,125 @@ func (qs *QueryService) Count(ctx context.Context, req QueryRequest) (*CountResp
 		return nil, newClientError("%v", err)
 	}
 
-	jobs := qs.buildInitialJobs(cp)
-	stats := QueryStats{}
+	cp.Filters, err = newCaptureFilters(cp, req.Filters)
+	if err != nil {
+		return nil, newClientError("%v", err)
+	}
+	// The program only depends on the captures, which the pattern and mode
+	// determine.
+	key := whereKey{pattern: pattern, mode: mode, expr: strings.TrimSpace(req.Where)}
+	if where, ok := qs.wheres.get(key); ok {
+		cp.Where = where
+		return cp, nil
+	}
+	cp.Where, err = compileWhere(cp, req.Where)
+	if err != nil {
+		return nil, newClientError("%v", err)
+	}
+	qs.wheres.put(key, cp.Where)
+	return cp, nil
+}
 
-	workerCount := qs.cfg.WorkerCount
-	if workerCount < 1 {
-		workerCount = 1
+// walk runs the whole traversal of a pattern. Unless visit is nil, it is
+// called with the matches of every objects job, in traversal order. Unless
+// skip is nil, jobs whose list prefix it reports are dropped unlisted.
+func (qs *QueryService) walk(ctx context.Context, client storage.Client, cp *compiledPattern, skip func(prefix string) bool, visit func([]objectMatch)) (QueryStats, error) {
+	collect := visit != nil
+	stats := QueryStats{}
+	keep := func(jobs []listJob) []listJob {
+		if skip == nil {
+			return jobs
+		}
+		return slices.DeleteFunc(jobs, func(job listJob) bool {
+			return skip(jobListPrefix(cp, job))
+		})
 	}
+	jobs := keep(qs.buildInitialJobs(cp, &stats))
 
 	objectBatchSize := qs.objectBatchSize(qs.cfg.MaxPageSize)
 
 	for len(jobs) > 0 {
-		batchSize := workerCount
-		if len(jobs) < batchSize {
-			batchSize = len(jobs)
-		}
+		var batch []jobTask
+		batch, jobs = qs.nextBatch(jobs, objectBatchSize)
 
-		finalBatch := make([]jobTask, 0, batchSize)
-		for len(finalBatch) < batchSize && len(jobs) > 0 {
-			job := jobs[0]
-			jobs = jobs[1:]
-			task := jobTask{job: job}
-			if job.Kind == jobKindObjects {
-				task.limit = objectBatchSize
+		for _, outcome := range qs.runBatch(ctx, client, cp, batch, collect) {
+			if outcome.err != nil {
+				return stats, outcome.err
+			}
+			stats.add(outcome.stats)
+			if collect {
+				visit(outcome.matches)
+			}
+			if len(outcome.newJobs) > 0 {
+				jobs = append(jobs, keep(outcome.newJobs)...)
 			}
-			finalBatch = append(finalBatch, task)
 		}
+	}
+	return stats, nil
+}
 
-		outcomes := make(chan jobOutcome, len(finalBatch))
-		var wg sync.WaitGroup
-
-		for _, task := range finalBatch {
-			wg.Add(1)
-			go func(task jobTask) {
-				defer wg.Done()
-				localStats := QueryStats{}
-				switch task.job.Kind {
-				case jobKindSegment:
-					additionalJobs, err := qs.processSegmentJob(ctx, cp, task.job, &localStats)
-					outcomes <- jobOutcome{
-						newJobs: additionalJobs,
-						stats:   localStats,
-						err:     err,
-					}
-				case jobKindObjects:
-					_, nextJobs, err := qs.processObjectsJob(ctx, cp, task.job, task.limit, &localStats, false)
-					outcomes <- jobOutcome{
-						newJobs: nextJobs,
-						stats:   localStats,
-						err:     err,
-					}
-				default:
-					outcomes <- jobOutcome{err: fmt.Errorf("unknown job kind: %s", task.job.Kind)}
-				}
-			}(task)
-		}
+// nextBatch takes up to one job per worker from the front of the queue.
+func (qs *QueryService) nextBatch(jobs []listJob, objectBatchSize int) ([]jobTask, []listJob) {
+	workerCount := qs.cfg.WorkerCount
+	if workerCount < 1 {
+		workerCount = 1
+	}
+	batchSize := min(workerCount, len(jobs))
 
-		wg.Wait()
-		close(outcomes)
+	batch := make([]jobTask, 0, batchSize)
+	for _, job := range jobs[:batchSize] {
+		task := jobTask{job: job}
+		if job.Kind == jobKindObjects {
+			task.limit = objectBatchSize
+		}
+		batch = append(batch, task)
+	}
+	return batch, jobs[batchSize:]
+}
 
-		for outcome := range outcomes {
-			if outcome.err != nil {
-				return nil, outcome.err
-			}
-			stats.ScannedPrefixes += outcome.stats.ScannedPrefixes
-			stats.ScannedObjects += outcome.stats.ScannedObjects
-			stats.Matched += outcome.stats.Matched
-			if len(outcome.newJobs) > 0 {
-				jobs = append(jobs, outcome.newJobs...)
+// runBatch processes a batch of jobs in parallel. Outcomes are returned in
+// batch order rather than completion order, so the same query always yields
+// its items and follow-up jobs in the same order.
+func (qs *QueryService) runBatch(ctx context.Context, client storage.Client, cp *compiledPattern, batch []jobTask, collect bool) []jobOutcome {
+	outcomes := make([]jobOutcome, len(batch))
+	var wg sync.WaitGroup
+
+	for i, task := range batch {
+		wg.Add(1)
+		go func(i int, task jobTask) {
+			defer wg.Done()
+			localStats := QueryStats{}
+			switch task.job.Kind {
+			case jobKindSegment:
+				additionalJobs, err := qs.processSegmentJob(ctx, client, cp, task.job, &localStats)
+				outcomes[i] = jobOutcome{
+					newJobs: additionalJobs,
+					stats:   localStats,
+					err:     err,
+				}
+			case jobKindObjects:
+				matches, nextJobs, err := qs.processObjectsJob(ctx, client, cp, task.job, task.limit, &localStats, collect)
+				outcomes[i] = jobOutcome{
+					matches: matches,
+					newJobs: nextJobs,
+					stats:   localStats,
+					err:     err,
+				}
+			default:
+				outcomes[i] = jobOutcome{
+					err: fmt.Errorf("unknown job kind: %s", task.job.Kind),
+				}
 			}
-		}
+		}(i, task)
 	}
 
-	return &CountResponse{
-		Total: stats.Matched,
-		Stats: stats,
-	}, nil
+	wg.Wait()
+	return outcomes
 }
 
-func (qs *QueryService) buildInitialJobs(cp *compiledPattern) []listJob {
+// buildInitialJobs returns the listings a traversal starts with. Enumerated
+// values that filters reject are counted in stats as pruned.
+func (qs *QueryService) buildInitialJobs(cp *compiledPattern, stats *QueryStats) []listJob {
 	if len(cp.Segments) == 0 {
 		return []listJob{{
 			Kind:         jobKindObjects,
@@ -323,38 +392,97 @@ func (qs *QueryService) buildInitialJobs(cp *compiledPattern) []listJob {
 		}}
 	}
 
-	var jobs []listJob
 	prefix, idx := advanceLiteralSegments("", 0, cp.Segments)
 	if idx >= len(cp.Segments) {
-		return jobs
+		return nil
 	}
-	if idx == len(cp.Segments)-1 {
-		jobs = append(jobs, listJob{
-			Kind:         jobKindObjects,
-			SegmentIndex: idx,
-			Prefix:       prefix,
-		})
-	} else {
+	return segmentJobs(cp, idx, prefix, nil, stats)
+}
+
+// maxExpandedJobs bounds how many jobs the known values of enumerated
+// segments may expand into before they are listed instead.
+const maxExpandedJobs = 1024
+
+// segmentJobs lists segment idx below prefix, one job per alternative literal
+// prefix of the segment. Enumerated segments are not listed at all: their
+// values are descended into directly. bound holds the captures earlier
+// segments matched, which repeated capture names must agree with and filters
+// must admit; values they reject are counted in stats as pruned.
+func segmentJobs(cp *compiledPattern, idx int, prefix string, bound map[string]string, stats *QueryStats) []listJob {
+	seg := cp.Segments[idx]
+	last := idx == len(cp.Segments)-1
+	if seg.Values != nil && !last {
+		var jobs []listJob
+		// Pruning is only counted when the expansion is kept; otherwise the
+		// listing below counts the prefixes it skips.
+		expanded := QueryStats{}
+		for _, value := range seg.Values {
+			nextBound, ok := seg.bind(value, bound)
+			if !ok {
+				continue
+			}
+			if !cp.admitsBound(nextBound) {
+				expanded.PrunedPrefixes++
+				continue
+			}
+			next, nextIdx := advanceLiteralSegments(joinPath(prefix, value), idx+1, cp.Segments)
+			jobs = append(jobs, segmentJobs(cp, nextIdx, next, nextBound, &expanded)...)
+			if len(jobs) > maxExpandedJobs {
+				break
+			}
+		}
+		if len(jobs) <= maxExpandedJobs {
+			stats.add(expanded)
+			return jobs
+		}
+	}
+
+	kind := jobKindSegment
+	literals := seg.boundPrefixes(bound)
+	if last {
+		kind = jobKindObjects
+		if seg.Values != nil {
+			var values []string
+			for _, value := range seg.Values {
+				valueBound, ok := seg.bind(value, bound)
+				if !ok {
+					continue
+				}
+				if !cp.admitsBound(valueBound) {
+					stats.PrunedPrefixes++
+					continue
+				}
+				values = append(values, value)
+			}
+			// Listing "a" already covers "ab", so overlapping values
+			// would return objects twice.
+			literals = minimizePrefixes(values)
+		}
+	}
+	jobs := make([]listJob, 0, len(literals))
+	for _, literal := range literals {
 		jobs = append(jobs, listJob{
-			Kind:         jobKindSegment,
+			Kind:         kind,
 			SegmentIndex: idx,
 			Prefix:       prefix,
+			Literal:      literal,
+			Bound:        bound,
 		})
 	}
 	return jobs
 }
 
-func (qs *QueryService) processSegmentJob(ctx context.Context, cp *compiledPattern, job listJob, stats *QueryStats) ([]listJob, error) {
+func (qs *QueryService) processSegmentJob(ctx context.Context, client storage.Client, cp *compiledPattern, job listJob, stats *QueryStats) ([]listJob, error) {
 	if job.SegmentIndex < 0 || job.SegmentIndex >= len(cp.Segments) {
 		return nil, fmt.Errorf("segment index out of range")
 	}
 	seg := cp.Segments[job.SegmentIndex]
 	basePrefix := job.Prefix
-	listPrefix := joinPath(basePrefix, seg.LiteralPrefix)
+	listPrefix := job.listPrefix()
 
-	resp, err := qs.storage.List(ctx, storage.ListRequest{
+	resp, err := client.List(ctx, storage.ListRequest{
 		Bucket:    cp.Bucket,
-		Prefix:    ensureTrailingSlash(listPrefix),
+		Prefix:    listPrefix,
 		Delimiter: "/",
 		PageToken: job.PageToken,
 		PageSize:  qs.cfg.MaxPageSize,
@@ -364,6 +492,9 @@ func (qs *QueryService) processSegmentJob(ctx context.Context, cp *compiledPatte
 	}
 
 	stats.ScannedPrefixes += len(resp.Prefixes)
+	stats.Retries += resp.Retries
+	stats.CacheHits += resp.CacheHits
+	stats.CacheMisses += resp.CacheMisses
 
 	var newJobs []listJob
 	for _, prefix := range resp.Prefixes {
@@ -371,7 +502,12 @@ func (qs *QueryService) processSegmentJob(ctx context.Context, cp *compiledPatte
 		if segmentValue == "" {
 			continue
 		}
-		if !seg.Regex.MatchString(segmentValue) {
+		bound, ok := seg.bind(segmentValue, job.Bound)
+		if !ok {
+			continue
+		}
+		if !cp.admitsBound(bound) {
+			stats.PrunedPrefixes++
 			continue
 		}
 
@@ -382,20 +518,7 @@ func (qs *QueryService) processSegmentJob(ctx context.Context, cp *compiledPatte
 		if nextIndex >= len(cp.Segments) {
 			continue
 		}
-
-		if nextIndex == len(cp.Segments)-1 {
-			newJobs = append(newJobs, listJob{
-				Kind:         jobKindObjects,
-				SegmentIndex: nextIndex,
-				Prefix:       nextPrefix,
-			})
-		} else {
-			newJobs = append(newJobs, listJob{
-				Kind:         jobKindSegment,
-				SegmentIndex: nextIndex,
-				Prefix:       nextPrefix,
-			})
-		}
+		newJobs = append(newJobs, segmentJobs(cp, nextIndex, nextPrefix, bound, stats)...)
 	}
 
 	if resp.NextPageToken != "" {
@@ -404,37 +527,31 @@ func (qs *QueryService) processSegmentJob(ctx context.Context, cp *compiledPatte
 			SegmentIndex: job.SegmentIndex,
 			Prefix:       job.Prefix,
 			PageToken:    resp.NextPageToken,
+			Literal:      job.Literal,
+			Bound:        job.Bound,
 		})
 	}
 
 	return newJobs, nil
 }
 
-func (qs *QueryService) processObjectsJob(ctx context.Context, cp *compiledPattern, job listJob, limit int, stats *QueryStats, collect bool) ([]QueryItem, []listJob, error) {
+func (qs *QueryService) processObjectsJob(ctx context.Context, client storage.Client, cp *compiledPattern, job listJob, limit int, stats *QueryStats, collect bool) ([]objectMatch, []listJob, error) {
 	if limit <= 0 {
 		limit = qs.cfg.MaxPageSize
 	}
-
-	var objectPrefix string
-	if len(cp.Segments) == 0 || job.SegmentIndex < 0 {
-		objectPrefix = cp.LiteralPrefix
-	} else {
-		if job.SegmentIndex >= len(cp.Segments) {
-			return nil, nil, fmt.Errorf("segment index out of range")
-		}
-		finalSeg := cp.Segments[job.SegmentIndex]
-		basePrefix := job.Prefix
-		objectPrefix = joinPath(basePrefix, finalSeg.LiteralPrefix)
+	if job.SegmentIndex >= len(cp.Segments) {
+		return nil, nil, fmt.Errorf("segment index out of range")
 	}
+	objectPrefix := jobListPrefix(cp, job)
 
 	remaining := limit
 	nextToken := job.PageToken
 	pagesRemaining := qs.prefetchPageCount()
-	var items []QueryItem
+	var matches []objectMatch
 
 	for remaining > 0 && pagesRemaining > 0 {
 		pageSize := min(remaining, qs.cfg.MaxPageSize)
-		resp, err := qs.storage.List(ctx, storage.ListRequest{
+		resp, err := client.List(ctx, storage.ListRequest{
 			Bucket:    cp.Bucket,
 			Prefix:    objectPrefix,
 			PageToken: nextToken,
@@ -444,29 +561,19 @@ func (qs *QueryService) processObjectsJob(ctx context.Context, cp *compiledPatte
 			return nil, nil, err
 		}
 
+		stats.Retries += resp.Retries
+		stats.CacheHits += resp.CacheHits
+		stats.CacheMisses += resp.CacheMisses
 		for _, obj := range resp.Objects {
 			stats.ScannedObjects++
-			matches := cp.Matcher.FindStringSubmatch(obj.Name)
-			if matches == nil {
+			captures, ok := cp.match(obj.Name)
+			if !ok || !cp.Where.admits(obj, captures) {
 				continue
 			}
 
 			stats.Matched++
 			if collect {
-				captures := make(map[string]string, len(cp.CaptureNames))
-				for i, name := range cp.SubexpNames {
-					if i == 0 || name == "" {
-						continue
-					}
-					if i < len(matches) {
-						captures[name] = matches[i]
-					}
-				}
-				items = append(items, QueryItem{
-					Object:   obj.Name,
-					URL:      fmt.Sprintf("https://storage.googleapis.com/%s/%s", cp.Bucket, obj.Name),
-					Captures: captures,
-				})
+				matches = append(matches, objectMatch{Object: obj, Captures: captures})
 			}
 
 			remaining--
@@ -494,10 +601,106 @@ func (qs *QueryService) processObjectsJob(ctx context.Context, cp *compiledPatte
 			SegmentIndex: job.SegmentIndex,
 			Prefix:       job.Prefix,
 			PageToken:    nextToken,
+			Literal:      job.Literal,
+			Bound:        job.Bound,
 		})
 	}
 
-	return items, nextJobs, nil
+	return matches, nextJobs, nil
+}
+
+// jobListPrefix is the prefix every object a job can reach starts with. The
+// job that lists a pattern without segments uses its literal prefix as is.
+func jobListPrefix(cp *compiledPattern, job listJob) string {
+	if len(cp.Segments) == 0 || job.SegmentIndex < 0 {
+		return cp.LiteralPrefix
+	}
+	return job.listPrefix()
+}
+
+// newItem builds the response item of a match.
+func (qs *QueryService) newItem(client storage.Client, cp *compiledPattern, match objectMatch, withMetadata bool) QueryItem {
+	item := QueryItem{
+		Object:   match.Object.Name,
+		URL:      qs.objectURL(client, cp, match.Object.Name),
+		ThumbURL: qs.thumbURL(cp, match.Object.Name),
+		Captures: match.Captures,
+	}
+	if withMetadata {
+		item.Metadata = newObjectMetadata(match.Object)
+	}
+	return item
+}
+
+// ReadObject opens an object URI, or a byte range of it, so its contents can
+// be served back through the API.
+func (qs *QueryService) ReadObject(ctx context.Context, uri string, offset, length int64) (*storage.ObjectReader, error) {
+	loc, err := storage.ParseLocation(uri)
+	if err != nil {
+		return nil, newClientError("%v", err)
+	}
+	client, err := qs.clientFor(loc.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return client.Read(ctx, storage.ReadRequest{
+		Bucket: loc.Bucket,
+		Name:   loc.Path,
+		Offset: offset,
+		Length: length,
+	})
+}
+
+// StatObject returns the metadata of an object URI without reading it.
+func (qs *QueryService) StatObject(ctx context.Context, uri string) (*storage.Object, error) {
+	loc, err := storage.ParseLocation(uri)
+	if err != nil {
+		return nil, newClientError("%v", err)
+	}
+	client, err := qs.clientFor(loc.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return client.Stat(ctx, storage.StatRequest{Bucket: loc.Bucket, Name: loc.Path})
+}
+
+func (qs *QueryService) clientFor(scheme string) (storage.Client, error) {
+	client, ok := qs.registry.Lookup(scheme)
+	if !ok {
+		return nil, newClientError("storage scheme %s:// is not enabled (available: %s)", scheme, strings.Join(qs.registry.Schemes(), ", "))
+	}
+	return client, nil
+}
+
+// objectURL returns the address the frontend loads an object from. Objects
+// are proxied by the API unless public or signed URLs are configured and the
+// backend can provide them.
+func (qs *QueryService) objectURL(client storage.Client, cp *compiledPattern, name string) string {
+	switch qs.cfg.ObjectURLs {
+	case config.ObjectURLsPublic:
+		if public, ok := storage.Unwrap(client).(storage.PublicURLer); ok {
+			return public.PublicURL(cp.Bucket, name)
+		}
+	case config.ObjectURLsSigned:
+		if signer, ok := storage.Unwrap(client).(storage.URLSigner); ok {
+			if signed, err := signer.SignedURL(cp.Bucket, name, qs.cfg.SignedURLExpiry); err == nil {
+				return signed
+			}
+		}
+	}
+	loc := storage.Location{Scheme: cp.Scheme, Bucket: cp.Bucket, Path: name}
+	return "/api/object?" + url.Values{"object": {loc.String()}}.Encode()
+}
+
+// thumbURL returns the address of the object's thumbnail, which is always
+// rendered by the API.
+func (qs *QueryService) thumbURL(cp *compiledPattern, name string) string {
+	loc := storage.Location{Scheme: cp.Scheme, Bucket: cp.Bucket, Path: name}
+	values := url.Values{"object": {loc.String()}}
+	if qs.cfg.ThumbWidth > 0 {
+		values.Set("w", strconv.Itoa(qs.cfg.ThumbWidth))
+	}
+	return "/api/thumb?" + values.Encode()
 }
 
 func (qs *QueryService) decodeCursor(encoded string) (*cursorState, error) {
@@ -505,8 +708,12 @@ func (qs *QueryService) decodeCursor(encoded string) (*cursorState, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Numbers keep their exact form so int captures of pending items survive
+	// the round trip.
+	decoder := json.NewDecoder(strings.NewReader(string(bytes)))
+	decoder.UseNumber()
 	var state cursorState
-	if err := json.Unmarshal(bytes, &state); err != nil {
+	if err := decoder.Decode(&state); err != nil {
 		return nil, err
 	}
 	return &state, nil
@@ -0,0 +1,813 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/worldlabs/image-grid-viewer/backend/config"
+	"github.com/worldlabs/image-grid-viewer/backend/storage"
+)
+
+func newTestService(objects ...string) (*QueryService, *storage.MemoryClient) {
+	mem := storage.NewMemoryClient()
+	mem.AddObjects("bucket", objects...)
+	registry := storage.NewRegistry()
+	registry.Register(storage.SchemeMemory, mem)
+	cfg := config.Config{
+		WorkerCount:     4,
+		DefaultPageSize: 10,
+		MinPageSize:     1,
+		MaxPageSize:     50,
+		PrefetchPages:   1,
+	}
+	return NewQueryService(cfg, registry), mem
+}
+
+// renderTree lays out runs/run_<exp>/img_<idx>.jpg plus some noise that the
+// test patterns must not match.
+func renderTree(exps, perExp int) ([]string, []string) {
+	var objects, matches []string
+	for e := 0; e < exps; e++ {
+		for i := 0; i < perExp; i++ {
+			name := fmt.Sprintf("runs/run_e%d/img_%02d.jpg", e, i)
+			objects = append(objects, name)
+			matches = append(matches, name)
+		}
+		objects = append(objects, fmt.Sprintf("runs/run_e%d/img_%02d.png", e, 0))
+		objects = append(objects, fmt.Sprintf("runs/run_e%d/nested/img_00.jpg", e))
+	}
+	objects = append(objects, "runs/other/img_00.jpg", "runs/run_x.jpg")
+	return objects, matches
+}
+
+func queryAll(t *testing.T, qs *QueryService, req QueryRequest) []QueryItem {
+	t.Helper()
+	var items []QueryItem
+	for page := 0; ; page++ {
+		if page > 100 {
+			t.Fatal("pagination did not terminate")
+		}
+		resp, err := qs.Query(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Query returned error: %v", err)
+		}
+		if len(resp.Items) > req.PageSize {
+			t.Fatalf("page %d has %d items, more than page size %d", page, len(resp.Items), req.PageSize)
+		}
+		items = append(items, resp.Items...)
+		if resp.NextCursor == nil {
+			return items
+		}
+		req.Cursor = *resp.NextCursor
+	}
+}
+
+func assertSameObjects(t *testing.T, items []QueryItem, want []string) {
+	t.Helper()
+	got := make([]string, len(items))
+	for i, item := range items {
+		got[i] = item.Object
+	}
+	sort.Strings(got)
+	want = append([]string(nil), want...)
+	sort.Strings(want)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d objects, got %d: %v", len(want), len(got), got)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("object mismatch at %d: got %s want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestQueryPaginatesAllMatches(t *testing.T) {
+	objects, matches := renderTree(3, 7)
+	qs, _ := newTestService(objects...)
+
+	items := queryAll(t, qs, QueryRequest{Pattern: "mem://bucket/runs/run_%exp%/img_%idx%.jpg", PageSize: 4})
+	assertSameObjects(t, items, matches)
+
+	for _, item := range items {
+		want := fmt.Sprintf("runs/run_%s/img_%s.jpg", item.Captures["exp"], item.Captures["idx"])
+		if item.Object != want {
+			t.Fatalf("captures %v do not reproduce %s", item.Captures, item.Object)
+		}
+	}
+}
+
+func TestQueryListsLiteralPrefixWithinParent(t *testing.T) {
+	qs, _ := newTestService("data/cam_1/x.jpg", "data/cam_2/x.jpg", "data/lidar/x.jpg", "data/e1/a.jpg", "data/e10/a.jpg")
+
+	// cam_%n% lists "data/cam_", not the directory "data/cam_/".
+	resp, err := qs.Count(context.Background(), QueryRequest{Pattern: "mem://bucket/data/cam_%n%/x.jpg"})
+	if err != nil {
+		t.Fatalf("Count returned error: %v", err)
+	}
+	if resp.Total != 2 {
+		t.Fatalf("expected 2 matches below data/cam_, got %d", resp.Total)
+	}
+
+	// Objects of data/e1 are listed under "data/e1/", so the sibling
+	// data/e10 is not scanned.
+	resp, err = qs.Count(context.Background(), QueryRequest{Pattern: "mem://bucket/data/%exp%/%name%.jpg"})
+	if err != nil {
+		t.Fatalf("Count returned error: %v", err)
+	}
+	if resp.Total != 5 || resp.Stats.ScannedObjects != 5 {
+		t.Fatalf("expected 5 matches from 5 scanned objects, got %d from %d", resp.Total, resp.Stats.ScannedObjects)
+	}
+}
+
+func TestQueryCarriesSurplusOfParallelJobs(t *testing.T) {
+	objects, matches := renderTree(4, 3)
+	qs, _ := newTestService(objects...)
+	req := QueryRequest{Pattern: "mem://bucket/runs/run_%exp%/img_%idx%.jpg", PageSize: 2}
+
+	// The four runs are listed in parallel and together return more than
+	// the page holds; the rest must reach later pages.
+	resp, err := qs.Query(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	if len(resp.Items) != 2 || resp.NextCursor == nil {
+		t.Fatalf("expected a full page and a cursor, got %d items", len(resp.Items))
+	}
+
+	assertSameObjects(t, queryAll(t, qs, req), matches)
+}
+
+func TestQueryRegexMode(t *testing.T) {
+	objects, matches := renderTree(2, 3)
+	qs, _ := newTestService(objects...)
+
+	items := queryAll(t, qs, QueryRequest{Pattern: `mem://bucket/runs/run_(?<exp>e[0-9])/img_(?<idx>[0-9]+)\.jpg`, Mode: "regex", PageSize: 2})
+	assertSameObjects(t, items, matches)
+}
+
+func TestQueryRegexAlternativesListInParallel(t *testing.T) {
+	objects, _ := renderTree(4, 3)
+	qs, mem := newTestService(objects...)
+
+	items := queryAll(t, qs, QueryRequest{Pattern: `mem://bucket/runs/(?P<exp>run_e0|run_e2)/img_(?P<idx>[0-9]+)\.jpg`, Mode: "regex", PageSize: 4})
+	var want []string
+	for _, name := range objects {
+		if strings.HasPrefix(name, "runs/run_e0/img_") || strings.HasPrefix(name, "runs/run_e2/img_") {
+			if strings.HasSuffix(name, ".jpg") {
+				want = append(want, name)
+			}
+		}
+	}
+	assertSameObjects(t, items, want)
+
+	listed := map[string]bool{}
+	for _, req := range mem.Requests() {
+		listed[req.Prefix] = true
+	}
+	if !listed["runs/run_e0"] || !listed["runs/run_e2"] || listed["runs/"] {
+		t.Fatalf("expected one listing per alternative, got %v", listed)
+	}
+}
+
+func TestCountRegexModeListsBySegment(t *testing.T) {
+	objects, matches := renderTree(3, 4)
+	qs, mem := newTestService(objects...)
+
+	resp, err := qs.Count(context.Background(), QueryRequest{Pattern: `mem://bucket/runs/run_(?<exp>e[0-9])/img_(?<idx>[0-9]+)\.jpg`, Mode: "regex"})
+	if err != nil {
+		t.Fatalf("Count returned error: %v", err)
+	}
+	if resp.Total != len(matches) || resp.Stats.ScannedPrefixes == 0 {
+		t.Fatalf("unexpected count: %+v", resp)
+	}
+	delimited := 0
+	for _, req := range mem.Requests() {
+		if req.Delimiter == "/" {
+			delimited++
+		}
+	}
+	if delimited == 0 {
+		t.Fatal("regex pattern was listed without a delimiter")
+	}
+}
+
+func TestQueryReturnsTypedCaptures(t *testing.T) {
+	qs, _ := newTestService("days/2024-05-01/img_2.jpg", "days/2024-05-01/img_10.jpg", "days/2024-02-30/img_1.jpg", "days/latest/img_1.jpg")
+
+	resp, err := qs.Query(context.Background(), QueryRequest{Pattern: "mem://bucket/days/%day:date(2006-01-02)%/img_%idx:int%.jpg", PageSize: 10})
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	if resp.CaptureTypes["day"] != CaptureDate || resp.CaptureTypes["idx"] != CaptureInt {
+		t.Fatalf("unexpected capture types: %v", resp.CaptureTypes)
+	}
+	assertSameObjects(t, resp.Items, []string{"days/2024-05-01/img_2.jpg", "days/2024-05-01/img_10.jpg"})
+	for _, item := range resp.Items {
+		if _, ok := item.Captures["idx"].(int64); !ok {
+			t.Fatalf("idx is not an int: %#v", item.Captures["idx"])
+		}
+		if day, ok := item.Captures["day"].(time.Time); !ok || !day.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Fatalf("unexpected day: %#v", item.Captures["day"])
+		}
+	}
+}
+
+func TestQueryRecursiveCaptureListsFlat(t *testing.T) {
+	qs, mem := newTestService(
+		"runs/d1/frame_0001.png",
+		"runs/d1/a/frame_0001.png",
+		"runs/d1/a/b/c/frame_0002.png",
+		"runs/d2/x/frame_0003.png",
+		"runs/d2/x/notes.txt",
+	)
+
+	items := queryAll(t, qs, QueryRequest{Pattern: "mem://bucket/runs/%date%/%path**%/frame_%n%.png", PageSize: 10})
+	assertSameObjects(t, items, []string{"runs/d1/a/frame_0001.png", "runs/d1/a/b/c/frame_0002.png", "runs/d2/x/frame_0003.png"})
+	for _, item := range items {
+		if item.Object == "runs/d1/a/b/c/frame_0002.png" && item.Captures["path"] != "a/b/c" {
+			t.Fatalf("unexpected path capture: %v", item.Captures)
+		}
+	}
+
+	for _, req := range mem.Requests() {
+		if strings.HasPrefix(req.Prefix, "runs/d") && req.Delimiter != "" {
+			t.Fatalf("expected flat listing below the recursive capture, got %+v", req)
+		}
+	}
+}
+
+func TestQueryExpandsEnumerationsWithoutListing(t *testing.T) {
+	qs, mem := newTestService(
+		"data/train/frame_0000.png",
+		"data/train/frame_0002.png",
+		"data/train/frame_0005.png",
+		"data/val/frame_0001.png",
+		"data/test/frame_0001.png",
+	)
+
+	items := queryAll(t, qs, QueryRequest{Pattern: "mem://bucket/data/{train,val}/frame_%n:{0000..0003}%.png", PageSize: 10})
+	assertSameObjects(t, items, []string{"data/train/frame_0000.png", "data/train/frame_0002.png", "data/val/frame_0001.png"})
+	if n, ok := items[0].Captures["n"].(int64); !ok || n > 3 {
+		t.Fatalf("expected an int frame number, got %#v", items[0].Captures["n"])
+	}
+
+	requests := mem.Requests()
+	if len(requests) != 8 {
+		t.Fatalf("expected one listing per expanded name, got %d", len(requests))
+	}
+	for _, req := range requests {
+		if req.Delimiter != "" || !strings.HasPrefix(req.Prefix, "data/train/frame_") && !strings.HasPrefix(req.Prefix, "data/val/frame_") {
+			t.Fatalf("unexpected listing: %+v", req)
+		}
+	}
+}
+
+func TestQueryGlobMode(t *testing.T) {
+	objects, matches := renderTree(2, 3)
+	qs, mem := newTestService(objects...)
+
+	items := queryAll(t, qs, QueryRequest{Pattern: "mem://bucket/runs/run_*/img_??.jpg", Mode: "glob", PageSize: 2})
+	assertSameObjects(t, items, matches)
+	if exp := items[0].Captures["$1"]; exp != "e0" && exp != "e1" {
+		t.Fatalf("unexpected positional capture: %v", items[0].Captures)
+	}
+	for _, req := range mem.Requests() {
+		if req.Prefix == "runs/run_" && req.Delimiter != "/" {
+			t.Fatalf("expected a delimited listing of the run segment, got %+v", req)
+		}
+	}
+}
+
+func TestQueryRepeatedCapturesMustAgree(t *testing.T) {
+	qs, mem := newTestService(
+		"e1/renders/e1_0.png",
+		"e1/renders/e1_1.png",
+		"e1/renders/e2_0.png",
+		"e2/renders/e2_0.png",
+		"e2/renders/e1_0.png",
+	)
+
+	for _, req := range []QueryRequest{
+		{Pattern: "mem://bucket/%exp%/renders/%exp%_%idx%.png", PageSize: 10},
+		{Pattern: `mem://bucket/(?P<exp>[^/]+)/renders/(?P<exp>[^/_]+)_(?P<idx>\d+)\.png`, Mode: "regex", PageSize: 10},
+	} {
+		items := queryAll(t, qs, req)
+		assertSameObjects(t, items, []string{"e1/renders/e1_0.png", "e1/renders/e1_1.png", "e2/renders/e2_0.png"})
+	}
+
+	listed := map[string]bool{}
+	for _, req := range mem.Requests() {
+		listed[req.Prefix] = true
+	}
+	if !listed["e1/renders/e1_"] || !listed["e2/renders/e2_"] {
+		t.Fatalf("expected listings narrowed by the bound capture, got %v", listed)
+	}
+}
+
+func TestQueryHandlesTruncatedPages(t *testing.T) {
+	objects, matches := renderTree(3, 5)
+	qs, mem := newTestService(objects...)
+	mem.InjectFault(storage.Fault{Truncate: 1})
+
+	items := queryAll(t, qs, QueryRequest{Pattern: "mem://bucket/runs/run_%exp%/img_%idx%.jpg", PageSize: 3})
+	assertSameObjects(t, items, matches)
+}
+
+func TestCountMatchesQuery(t *testing.T) {
+	objects, matches := renderTree(4, 6)
+	qs, mem := newTestService(objects...)
+	mem.InjectFault(storage.Fault{Prefix: "runs/run_e2/", Truncate: 2})
+
+	resp, err := qs.Count(context.Background(), QueryRequest{Pattern: "mem://bucket/runs/run_%exp%/img_%idx%.jpg"})
+	if err != nil {
+		t.Fatalf("Count returned error: %v", err)
+	}
+	if resp.Total != len(matches) {
+		t.Fatalf("expected %d matches, got %d", len(matches), resp.Total)
+	}
+	if resp.Stats.ScannedPrefixes == 0 || resp.Stats.ScannedObjects < len(matches) {
+		t.Fatalf("unexpected stats: %+v", resp.Stats)
+	}
+}
+
+func TestQueryPropagatesStorageErrors(t *testing.T) {
+	objects, _ := renderTree(2, 2)
+	qs, mem := newTestService(objects...)
+	mem.InjectFault(storage.Fault{Prefix: "runs/run_e1/", StatusCode: http.StatusServiceUnavailable})
+
+	_, err := qs.Count(context.Background(), QueryRequest{Pattern: "mem://bucket/runs/run_%exp%/img_%idx%.jpg"})
+	if err == nil {
+		t.Fatal("expected storage error")
+	}
+	if IsClientError(err) {
+		t.Fatalf("storage failure reported as client error: %v", err)
+	}
+}
+
+func TestQueryRejectsForeignCursor(t *testing.T) {
+	objects, _ := renderTree(2, 5)
+	qs, _ := newTestService(objects...)
+
+	resp, err := qs.Query(context.Background(), QueryRequest{Pattern: "mem://bucket/runs/run_%exp%/img_%idx%.jpg", PageSize: 2})
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	if resp.NextCursor == nil {
+		t.Fatal("expected a cursor")
+	}
+
+	_, err = qs.Query(context.Background(), QueryRequest{Pattern: "mem://bucket/runs/%exp%/img_%idx%.jpg", Cursor: *resp.NextCursor})
+	if !IsClientError(err) {
+		t.Fatalf("expected client error for mismatched cursor, got %v", err)
+	}
+}
+
+func TestQueryUnknownScheme(t *testing.T) {
+	qs, _ := newTestService()
+	_, err := qs.Query(context.Background(), QueryRequest{Pattern: "s3://bucket/%exp%.jpg"})
+	if !IsClientError(err) {
+		t.Fatalf("expected client error for unregistered scheme, got %v", err)
+	}
+}
+
+func TestCountReportsRetries(t *testing.T) {
+	objects, matches := renderTree(2, 3)
+	mem := storage.NewMemoryClient()
+	mem.AddObjects("bucket", objects...)
+	mem.InjectFault(storage.Fault{Prefix: "runs/run_e1/", StatusCode: http.StatusTooManyRequests, Times: 2})
+	registry := storage.NewRegistry()
+	registry.Register(storage.SchemeMemory, storage.NewRetryClient(mem, storage.RetryPolicy{MaxAttempts: 3}))
+	qs := NewQueryService(config.Config{WorkerCount: 2, MinPageSize: 1, MaxPageSize: 50}, registry)
+
+	resp, err := qs.Count(context.Background(), QueryRequest{Pattern: "mem://bucket/runs/run_%exp%/img_%idx%.jpg"})
+	if err != nil {
+		t.Fatalf("Count returned error: %v", err)
+	}
+	if resp.Total != len(matches) || resp.Stats.Retries != 2 {
+		t.Fatalf("unexpected count response: %+v", resp)
+	}
+}
+
+func TestQueryReportsMissingBucket(t *testing.T) {
+	qs, _ := newTestService("runs/a.jpg")
+	_, err := qs.Query(context.Background(), QueryRequest{Pattern: "mem://other/runs/%name%.jpg"})
+	if !errors.Is(err, storage.ErrBucketNotFound) || IsClientError(err) {
+		t.Fatalf("expected bucket not found, got %v", err)
+	}
+}
+
+func TestQueryIncludesMetadataOnRequest(t *testing.T) {
+	qs, _ := newTestService("runs/a.jpg", "runs/b.jpg")
+	req := QueryRequest{Pattern: "mem://bucket/runs/%name%.jpg", PageSize: 10}
+
+	for _, item := range queryAll(t, qs, req) {
+		if item.Metadata != nil {
+			t.Fatalf("metadata returned without being requested: %+v", item)
+		}
+	}
+	req.IncludeMetadata = true
+	for _, item := range queryAll(t, qs, req) {
+		if item.Metadata == nil {
+			t.Fatalf("metadata missing: %+v", item)
+		}
+	}
+}
+
+func TestQueryItemsUseObjectProxy(t *testing.T) {
+	qs, mem := newTestService()
+	mem.PutObject("bucket", "runs/a #1.jpg", []byte("jpeg"))
+
+	items := queryAll(t, qs, QueryRequest{Pattern: "mem://bucket/runs/%name%.jpg", PageSize: 10})
+	if len(items) != 1 || items[0].URL != "/api/object?object=mem%3A%2F%2Fbucket%2Fruns%2Fa+%231.jpg" {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+	if items[0].ThumbURL != "/api/thumb?object=mem%3A%2F%2Fbucket%2Fruns%2Fa+%231.jpg" {
+		t.Fatalf("unexpected thumbnail url: %s", items[0].ThumbURL)
+	}
+
+	reader, err := qs.ReadObject(context.Background(), "mem://bucket/runs/a #1.jpg", 1, 2)
+	if err != nil {
+		t.Fatalf("ReadObject returned error: %v", err)
+	}
+	defer reader.Close()
+	if body, _ := io.ReadAll(reader); string(body) != "pe" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if _, err := qs.ReadObject(context.Background(), "mem://bucket", 0, 0); !IsClientError(err) {
+		t.Fatalf("expected client error for malformed uri, got %v", err)
+	}
+}
+
+// signingClient adds URL signing to a MemoryClient.
+type signingClient struct {
+	*storage.MemoryClient
+}
+
+func (signingClient) SignedURL(bucket, name string, expiry time.Duration) (string, error) {
+	return fmt.Sprintf("https://signed.example/%s/%s?expires=%d", bucket, name, int(expiry.Seconds())), nil
+}
+
+func TestQueryItemsUseSignedURLs(t *testing.T) {
+	mem := storage.NewMemoryClient()
+	mem.AddObjects("bucket", "runs/a.jpg")
+	registry := storage.NewRegistry()
+	registry.Register(storage.SchemeMemory, storage.NewRetryClient(signingClient{mem}, storage.RetryPolicy{}))
+	registry.Register(storage.SchemeS3, mem)
+	cfg := config.Config{
+		WorkerCount:     2,
+		MinPageSize:     1,
+		MaxPageSize:     50,
+		ObjectURLs:      config.ObjectURLsSigned,
+		SignedURLExpiry: 5 * time.Minute,
+	}
+	qs := NewQueryService(cfg, registry)
+
+	items := queryAll(t, qs, QueryRequest{Pattern: "mem://bucket/runs/%name%.jpg", PageSize: 10})
+	if len(items) != 1 || items[0].URL != "https://signed.example/bucket/runs/a.jpg?expires=300" {
+		t.Fatalf("expected signed url, got %+v", items)
+	}
+
+	// Backends that cannot sign fall back to the proxy.
+	items = queryAll(t, qs, QueryRequest{Pattern: "s3://bucket/runs/%name%.jpg", PageSize: 10})
+	if len(items) != 1 || !strings.HasPrefix(items[0].URL, "/api/object?") {
+		t.Fatalf("expected proxy url, got %+v", items)
+	}
+}
+
+func TestCountReusesCachedListings(t *testing.T) {
+	objects, matches := renderTree(3, 4)
+	mem := storage.NewMemoryClient()
+	mem.AddObjects("bucket", objects...)
+	registry := storage.NewRegistry()
+	registry.Register(storage.SchemeMemory, storage.NewCachingClient(mem, storage.CachePolicy{TTL: time.Minute, MaxEntries: 100}))
+	qs := NewQueryService(config.Config{WorkerCount: 2, MinPageSize: 1, MaxPageSize: 50}, registry)
+	req := QueryRequest{Pattern: "mem://bucket/runs/run_%exp%/img_%idx%.jpg"}
+
+	first, err := qs.Count(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Count returned error: %v", err)
+	}
+	listed := len(mem.Requests())
+	second, err := qs.Count(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Count returned error: %v", err)
+	}
+	if second.Total != len(matches) || len(mem.Requests()) != listed {
+		t.Fatalf("second count listed again: %+v", second)
+	}
+	if first.Stats.CacheMisses == 0 || second.Stats.CacheHits != first.Stats.CacheMisses || second.Stats.CacheMisses != 0 {
+		t.Fatalf("unexpected cache stats: %+v then %+v", first.Stats, second.Stats)
+	}
+}
+
+func TestQueryOrderIsDeterministic(t *testing.T) {
+	objects, _ := renderTree(6, 5)
+	qs, _ := newTestService(objects...)
+
+	req := QueryRequest{Pattern: "mem://bucket/runs/run_%exp%/img_%idx%.jpg", PageSize: 7}
+	first := queryAll(t, qs, req)
+	for run := 0; run < 5; run++ {
+		again := queryAll(t, qs, req)
+		for i := range first {
+			if again[i].Object != first[i].Object {
+				t.Fatalf("run %d: item %d is %s, previously %s", run, i, again[i].Object, first[i].Object)
+			}
+		}
+	}
+}
+
+func TestQuerySortsAcrossPages(t *testing.T) {
+	var objects []string
+	for e := 0; e < 3; e++ {
+		for _, idx := range []int{1, 2, 10, 20} {
+			objects = append(objects, fmt.Sprintf("runs/exp%d/frame_%d.png", e, idx))
+		}
+	}
+	qs, _ := newTestService(objects...)
+
+	items := queryAll(t, qs, QueryRequest{
+		Pattern:  "mem://bucket/runs/%exp%/frame_%n%.png",
+		PageSize: 5,
+		Sort:     []SortKey{{By: "n", Desc: true}, {By: "exp"}},
+	})
+	if len(items) != len(objects) {
+		t.Fatalf("expected %d items, got %d", len(objects), len(items))
+	}
+	for i, want := range []string{"runs/exp0/frame_20.png", "runs/exp1/frame_20.png", "runs/exp2/frame_20.png", "runs/exp0/frame_10.png"} {
+		if items[i].Object != want {
+			t.Fatalf("item %d: expected %s, got %s", i, want, items[i].Object)
+		}
+	}
+	if last := items[len(items)-1].Object; last != "runs/exp2/frame_1.png" {
+		t.Fatalf("expected natural order to end at frame_1, got %s", last)
+	}
+
+	items = queryAll(t, qs, QueryRequest{
+		Pattern:  "mem://bucket/runs/%exp%/frame_%n:int%.png",
+		PageSize: 4,
+		Sort:     []SortKey{{By: SortObject}},
+	})
+	if items[0].Object != "runs/exp0/frame_1.png" || items[3].Object != "runs/exp0/frame_20.png" {
+		t.Fatalf("unexpected object order: %s ... %s", items[0].Object, items[3].Object)
+	}
+}
+
+func TestQuerySortedByObjectResumesPastEarlierListings(t *testing.T) {
+	var objects []string
+	for e := 0; e < 12; e++ {
+		for _, idx := range []int{1, 2, 10} {
+			objects = append(objects, fmt.Sprintf("runs/e%d/frame_%d.png", e, idx))
+		}
+	}
+	// The test service keeps no snapshots, so every page walks the pattern.
+	qs, mem := newTestService(objects...)
+
+	req := QueryRequest{Pattern: "mem://bucket/runs/%exp%/frame_%n%.png", PageSize: 3, Sort: []SortKey{{By: SortObject}}}
+	var names []string
+	var scanned []int
+	for {
+		resp, err := qs.Query(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Query returned error: %v", err)
+		}
+		for _, item := range resp.Items {
+			names = append(names, item.Object)
+		}
+		scanned = append(scanned, resp.Stats.ScannedObjects)
+		if resp.NextCursor == nil {
+			break
+		}
+		req.Cursor = *resp.NextCursor
+	}
+
+	want := append([]string(nil), objects...)
+	sort.Slice(want, func(i, j int) bool { return naturalCompare(want[i], want[j]) < 0 })
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("unexpected order:\n got %v\nwant %v", names, want)
+	}
+	for i := 1; i < len(scanned); i++ {
+		if scanned[i] <= scanned[i-1] {
+			t.Fatalf("expected stats to accumulate across pages, got %v", scanned)
+		}
+	}
+
+	// The first page ends inside runs/e0, so the second lists it again; the
+	// pages after that skip it.
+	listed := 0
+	for _, listReq := range mem.Requests() {
+		if listReq.Prefix == "runs/e0/frame_" {
+			listed++
+		}
+	}
+	if listed != 2 || len(scanned) != 12 {
+		t.Fatalf("expected runs/e0 to be listed by 2 of %d pages, got %d listings", len(scanned), listed)
+	}
+}
+
+func TestQuerySortedServesLaterPagesFromSnapshot(t *testing.T) {
+	var objects []string
+	for e := 0; e < 6; e++ {
+		for _, idx := range []int{1, 2, 10} {
+			objects = append(objects, fmt.Sprintf("runs/e%d/frame_%d.png", e, idx))
+		}
+	}
+	want := append([]string(nil), objects...)
+	sort.Slice(want, func(i, j int) bool { return naturalCompare(want[i], want[j]) < 0 })
+	req := QueryRequest{Pattern: "mem://bucket/runs/%exp%/frame_%n%.png", PageSize: 3, Sort: []SortKey{{By: SortObject}}}
+
+	for _, tc := range []struct {
+		name  string
+		size  int
+		walks int
+	}{
+		{"whole", 100, 1},
+		// Each snapshot covers three pages, then the next page walks again.
+		{"partial", 9, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			qs, mem := newTestService(objects...)
+			qs.cfg.SortSnapshotSize = tc.size
+			qs.snapshots = newLRUCache[string, *sortSnapshot](4, time.Minute)
+
+			var names []string
+			walks := 0
+			req := req
+			for {
+				listed := len(mem.Requests())
+				resp, err := qs.Query(context.Background(), req)
+				if err != nil {
+					t.Fatalf("Query returned error: %v", err)
+				}
+				if len(mem.Requests()) > listed {
+					walks++
+				}
+				for _, item := range resp.Items {
+					names = append(names, item.Object)
+				}
+				if resp.NextCursor == nil {
+					break
+				}
+				req.Cursor = *resp.NextCursor
+			}
+			if !reflect.DeepEqual(names, want) {
+				t.Fatalf("unexpected order:\n got %v\nwant %v", names, want)
+			}
+			if walks != tc.walks {
+				t.Fatalf("expected %d pages to list, got %d", tc.walks, walks)
+			}
+		})
+	}
+
+	// A cursor whose snapshot is gone, e.g. one served by another replica,
+	// resumes by walking again.
+	qs, _ := newTestService(objects...)
+	qs.snapshots = newLRUCache[string, *sortSnapshot](4, time.Minute)
+	first, err := qs.Query(context.Background(), req)
+	if err != nil || first.NextCursor == nil {
+		t.Fatalf("expected a first page with a cursor, got %v", err)
+	}
+	other, mem := newTestService(objects...)
+	req.Cursor = *first.NextCursor
+	second, err := other.Query(context.Background(), req)
+	if err != nil || len(mem.Requests()) == 0 {
+		t.Fatalf("expected the second page to list again, got %v", err)
+	}
+	if second.Items[0].Object != want[3] {
+		t.Fatalf("expected the second page to start at %s, got %s", want[3], second.Items[0].Object)
+	}
+}
+
+func TestQueryRejectsInvalidSort(t *testing.T) {
+	objects, _ := renderTree(2, 5)
+	qs, _ := newTestService(objects...)
+	pattern := "mem://bucket/runs/run_%exp%/img_%idx%.jpg"
+
+	_, err := qs.Query(context.Background(), QueryRequest{Pattern: pattern, Sort: []SortKey{{By: "missing"}}})
+	if !IsClientError(err) {
+		t.Fatalf("expected client error for unknown capture, got %v", err)
+	}
+
+	resp, err := qs.Query(context.Background(), QueryRequest{Pattern: pattern, PageSize: 3})
+	if err != nil || resp.NextCursor == nil {
+		t.Fatalf("expected a first page with a cursor, got %v", err)
+	}
+	_, err = qs.Query(context.Background(), QueryRequest{Pattern: pattern, PageSize: 3, Cursor: *resp.NextCursor, Sort: []SortKey{{By: "idx"}}})
+	if !IsClientError(err) {
+		t.Fatalf("expected client error for a cursor from an unsorted query, got %v", err)
+	}
+}
+
+func TestQueryFiltersPruneSubtrees(t *testing.T) {
+	objects, _ := renderTree(4, 6)
+	qs, _ := newTestService(objects...)
+	maxIdx := 2.0
+
+	req := QueryRequest{
+		Pattern:  "mem://bucket/runs/run_%exp%/img_%idx%.jpg",
+		PageSize: 2,
+		Filters: map[string]CaptureFilter{
+			"exp": {NotIn: []string{"e0", "e3"}},
+			"idx": {Max: &maxIdx},
+		},
+	}
+	items := queryAll(t, qs, req)
+	assertSameObjects(t, items, []string{
+		"runs/run_e1/img_00.jpg", "runs/run_e1/img_01.jpg", "runs/run_e1/img_02.jpg",
+		"runs/run_e2/img_00.jpg", "runs/run_e2/img_01.jpg", "runs/run_e2/img_02.jpg",
+	})
+
+	resp, err := qs.Count(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Count returned error: %v", err)
+	}
+	if resp.Total != 6 || resp.Stats.PrunedPrefixes != 2 {
+		t.Fatalf("expected 6 matches and 2 pruned prefixes, got %d and %d", resp.Total, resp.Stats.PrunedPrefixes)
+	}
+	// Only the two admitted runs are listed for objects.
+	if resp.Stats.ScannedObjects != 2*(6+1) {
+		t.Fatalf("expected pruned runs not to be listed, scanned %d objects", resp.Stats.ScannedObjects)
+	}
+}
+
+func TestQueryFiltersPruneGlobCaptures(t *testing.T) {
+	objects, _ := renderTree(4, 2)
+	qs, _ := newTestService(objects...)
+
+	req := QueryRequest{
+		Pattern: "mem://bucket/runs/run_*/img_*.jpg",
+		Mode:    "glob",
+		Filters: map[string]CaptureFilter{"$1": {In: []string{"e1"}}},
+	}
+	resp, err := qs.Count(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Count returned error: %v", err)
+	}
+	if resp.Total != 2 || resp.Stats.PrunedPrefixes != 3 {
+		t.Fatalf("expected 2 matches and 3 pruned prefixes, got %d and %d", resp.Total, resp.Stats.PrunedPrefixes)
+	}
+}
+
+func TestQueryCountsPrunedEnumeratedValues(t *testing.T) {
+	objects, _ := renderTree(4, 3)
+	qs, mem := newTestService(objects...)
+
+	cases := map[string]CaptureFilter{
+		"mem://bucket/runs/run_{e0,e1,e2,e3}/img_*.jpg": {NotIn: []string{"e0", "e3"}},
+		"mem://bucket/runs/run_e1/img_{00,01,02}.jpg":   {In: []string{"00"}},
+	}
+	for pattern, filter := range cases {
+		resp, err := qs.Count(context.Background(), QueryRequest{
+			Pattern: pattern,
+			Mode:    "glob",
+			Filters: map[string]CaptureFilter{"$1": filter},
+		})
+		if err != nil {
+			t.Fatalf("%s: Count returned error: %v", pattern, err)
+		}
+		if resp.Stats.PrunedPrefixes != 2 {
+			t.Fatalf("%s: expected 2 pruned values, got %d", pattern, resp.Stats.PrunedPrefixes)
+		}
+	}
+	for _, req := range mem.Requests() {
+		if strings.HasPrefix(req.Prefix, "runs/run_e0") || strings.HasPrefix(req.Prefix, "runs/run_e1/img_01") {
+			t.Fatalf("pruned value was listed: %+v", req)
+		}
+	}
+}
+
+func TestQueryWherePrunesPrefixes(t *testing.T) {
+	objects, _ := renderTree(4, 12)
+	qs, _ := newTestService(objects...)
+
+	req := QueryRequest{
+		Pattern:  "mem://bucket/runs/run_%exp%/img_%idx:int%.jpg",
+		PageSize: 3,
+		Where:    "exp in ['e1', 'e3'] && idx % 5 == 0",
+	}
+	items := queryAll(t, qs, req)
+	assertSameObjects(t, items, []string{
+		"runs/run_e1/img_00.jpg", "runs/run_e1/img_05.jpg", "runs/run_e1/img_10.jpg",
+		"runs/run_e3/img_00.jpg", "runs/run_e3/img_05.jpg", "runs/run_e3/img_10.jpg",
+	})
+
+	resp, err := qs.Count(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Count returned error: %v", err)
+	}
+	if resp.Total != 6 || resp.Stats.PrunedPrefixes != 2 {
+		t.Fatalf("expected 6 matches and 2 pruned prefixes, got %d and %d", resp.Total, resp.Stats.PrunedPrefixes)
+	}
+
+	_, err = qs.Query(context.Background(), QueryRequest{Pattern: req.Pattern, Where: "exp > 3"})
+	if !IsClientError(err) {
+		t.Fatalf("expected type-check error to be a client error, got %v", err)
+	}
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/worldlabs/image-grid-viewer/backend/storage"
+)
+
+// resultSorter orders matches by a list of sort keys, falling back to the
+// object name so that the order is total.
+type resultSorter struct {
+	keys []SortKey
+}
+
+// newResultSorter validates the sort keys against the pattern's captures. It
+// returns nil when there is nothing to sort by.
+func newResultSorter(cp *compiledPattern, keys []SortKey) (*resultSorter, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	for _, key := range keys {
+		if key.By == "" {
+			return nil, fmt.Errorf("sort key is required")
+		}
+		if key.By != SortObject && !containsString(cp.CaptureNames, key.By) {
+			return nil, fmt.Errorf("cannot sort by unknown capture: %s", key.By)
+		}
+	}
+	return &resultSorter{keys: keys}, nil
+}
+
+func (s *resultSorter) compare(a, b objectMatch) int {
+	for _, key := range s.keys {
+		var c int
+		if key.By == SortObject {
+			c = naturalCompare(a.Object.Name, b.Object.Name)
+		} else {
+			c = compareCaptures(a.Captures[key.By], b.Captures[key.By])
+		}
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a.Object.Name, b.Object.Name)
+}
+
+// skipBefore returns a function reporting list prefixes whose every object
+// sorts at or before the object after, or nil when that cannot be told from
+// a prefix. Only the object name can: the first key then decides the order on
+// its own, since natural order breaks its ties by byte order.
+func (s *resultSorter) skipBefore(after string) func(prefix string) bool {
+	if after == "" || s.keys[0].By != SortObject {
+		return nil
+	}
+	desc := s.keys[0].Desc
+	return func(prefix string) bool {
+		order := naturalPrefixOrder(prefix, after)
+		if desc {
+			order = -order
+		}
+		return order < 0
+	}
+}
+
+// compareCaptures compares two values of the same capture, as produced by
+// compiledPattern.match.
+func compareCaptures(a, b interface{}) int {
+	switch x := a.(type) {
+	case int64:
+		y, _ := b.(int64)
+		return compareOrdered(x, y)
+	case float64:
+		y, _ := b.(float64)
+		return compareOrdered(x, y)
+	case time.Time:
+		y, _ := b.(time.Time)
+		return x.Compare(y)
+	}
+	x, _ := a.(string)
+	y, _ := b.(string)
+	return naturalCompare(x, y)
+}
+
+func compareOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// naturalCompare compares strings with runs of digits compared by numeric
+// value, so "frame_2" sorts before "frame_10". Strings that differ only in
+// leading zeros fall back to byte order.
+func naturalCompare(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			si, sj := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			x := strings.TrimLeft(a[si:i], "0")
+			y := strings.TrimLeft(b[sj:j], "0")
+			if len(x) != len(y) {
+				return compareOrdered(int64(len(x)), int64(len(y)))
+			}
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+			continue
+		}
+		if a[i] != b[j] {
+			return compareOrdered(int64(a[i]), int64(b[j]))
+		}
+		i++
+		j++
+	}
+	if c := compareOrdered(int64(len(a)-i), int64(len(b)-j)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// naturalPrefixOrder reports how every string starting with prefix compares
+// with s in natural order: -1 when all sort before s, 1 when all sort after
+// it, and 0 when it depends on what follows the prefix.
+func naturalPrefixOrder(prefix, s string) int {
+	// A trailing run of digits may continue past the prefix, so only the
+	// part before it is certain.
+	prefix = strings.TrimRight(prefix, "0123456789")
+	i, j := 0, 0
+	for i < len(prefix) && j < len(s) {
+		if isDigit(prefix[i]) && isDigit(s[j]) {
+			si, sj := i, j
+			for i < len(prefix) && isDigit(prefix[i]) {
+				i++
+			}
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			x := strings.TrimLeft(prefix[si:i], "0")
+			y := strings.TrimLeft(s[sj:j], "0")
+			if len(x) != len(y) {
+				return compareOrdered(int64(len(x)), int64(len(y)))
+			}
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+			continue
+		}
+		if prefix[i] != s[j] {
+			return compareOrdered(int64(prefix[i]), int64(s[j]))
+		}
+		i++
+		j++
+	}
+	return 0
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// sortSnapshot holds the smallest matches of a sorted query that sort after
+// the point it was taken from, in order, so that the pages after the first
+// are served without listing again.
+type sortSnapshot struct {
+	// query identifies the pattern, sort and filters the snapshot answers.
+	query   string
+	matches []objectMatch
+	// complete reports whether matches holds every match after that point.
+	complete bool
+}
+
+// sortSnapshotTTL bounds how long a sorted query's later pages are served
+// from its snapshot, and so how stale they can be.
+const sortSnapshotTTL = 10 * time.Minute
+
+// querySorted answers a sorted query page. Sorting needs every match, so a
+// page without a snapshot walks the pattern, keeping the names and captures
+// of up to SortSnapshotSize matches that sort after the previous page's last
+// object. The pages that follow are served from that snapshot until it runs
+// out, when the next page walks again. When the object name is the first
+// sort key, that walk skips listings that sort wholly before the cursor.
+func (qs *QueryService) querySorted(ctx context.Context, client storage.Client, cp *compiledPattern, sorter *resultSorter, req QueryRequest, pageSize int, state *cursorState) (*QueryResponse, error) {
+	filterKey, err := cp.Filters.key()
+	if err != nil {
+		return nil, err
+	}
+	where := strings.TrimSpace(req.Where)
+	query := fmt.Sprintf("%s\x00%s\x00%s\x00%v\x00%s\x00%s", cp.Raw, cp.Mode, cp.Bucket, sorter.keys, filterKey, where)
+
+	var after, snapshotID string
+	var last objectMatch
+	stats := QueryStats{}
+	if state != nil && state.After != "" {
+		captures, ok := cp.match(state.After)
+		if !ok {
+			return nil, newClientError("invalid cursor")
+		}
+		after = state.After
+		last = objectMatch{Object: storage.Object{Name: after}, Captures: captures}
+		stats = state.Stats
+		snapshotID = state.Snapshot
+	}
+
+	// Snapshots live in this process only; a cursor whose snapshot is gone
+	// resumes by walking from its last object.
+	snapshot, ok := qs.snapshots.get(snapshotID)
+	if !ok || snapshot.query != query {
+		snapshotID = ""
+		var walkStats QueryStats
+		snapshot, walkStats, err = qs.takeSnapshot(ctx, client, cp, sorter, after, last, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.query = query
+		stats.add(walkStats)
+	}
+
+	remaining := snapshot.matches
+	if after != "" {
+		remaining = remaining[sort.Search(len(remaining), func(i int) bool {
+			return sorter.compare(last, remaining[i]) < 0
+		}):]
+	}
+	matches := remaining[:min(pageSize, len(remaining))]
+
+	var nextCursor *string
+	if len(remaining) > pageSize || !snapshot.complete {
+		if len(remaining) <= pageSize {
+			snapshotID = ""
+		} else if snapshotID == "" && qs.snapshots != nil {
+			snapshotID, err = newSnapshotID()
+			if err != nil {
+				return nil, err
+			}
+			qs.snapshots.put(snapshotID, snapshot)
+		}
+		cursorValue, err := qs.encodeCursor(cursorState{
+			Pattern:  cp.Raw,
+			Mode:     cp.Mode,
+			Bucket:   cp.Bucket,
+			Stats:    stats,
+			Sort:     sorter.keys,
+			After:    matches[len(matches)-1].Object.Name,
+			Snapshot: snapshotID,
+			Filters:  filterKey,
+			Where:    where,
+		})
+		if err != nil {
+			return nil, err
+		}
+		nextCursor = &cursorValue
+	}
+
+	items := make([]QueryItem, len(matches))
+	for i, match := range matches {
+		items[i] = qs.newItem(client, cp, match, req.IncludeMetadata)
+	}
+
+	return &QueryResponse{
+		CaptureNames: cp.CaptureNames,
+		CaptureTypes: cp.captureKinds(),
+		Items:        items,
+		NextCursor:   nextCursor,
+		Stats:        stats,
+	}, nil
+}
+
+// takeSnapshot walks the pattern and keeps the smallest matches that sort
+// after last: a page's worth, or SortSnapshotSize when snapshots are kept.
+func (qs *QueryService) takeSnapshot(ctx context.Context, client storage.Client, cp *compiledPattern, sorter *resultSorter, after string, last objectMatch, pageSize int) (*sortSnapshot, QueryStats, error) {
+	limit := pageSize
+	if qs.snapshots != nil {
+		limit = max(limit, qs.cfg.SortSnapshotSize)
+	}
+	selected := &boundedMatches{sorter: sorter, limit: limit}
+	offered := 0
+	stats, err := qs.walk(ctx, client, cp, sorter.skipBefore(after), func(matches []objectMatch) {
+		for _, match := range matches {
+			if after == "" || sorter.compare(last, match) < 0 {
+				selected.offer(match)
+				offered++
+			}
+		}
+	})
+	if err != nil {
+		return nil, stats, err
+	}
+	return &sortSnapshot{matches: selected.sorted(), complete: offered <= limit}, stats, nil
+}
+
+func newSnapshotID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// boundedMatches keeps the limit smallest matches offered to it, as a heap
+// with the largest kept match on top.
+type boundedMatches struct {
+	sorter  *resultSorter
+	limit   int
+	matches []objectMatch
+}
+
+func (b *boundedMatches) offer(match objectMatch) {
+	if len(b.matches) < b.limit {
+		heap.Push(b, match)
+		return
+	}
+	if b.sorter.compare(match, b.matches[0]) < 0 {
+		b.matches[0] = match
+		heap.Fix(b, 0)
+	}
+}
+
+func (b *boundedMatches) sorted() []objectMatch {
+	sort.Slice(b.matches, func(i, j int) bool {
+		return b.sorter.compare(b.matches[i], b.matches[j]) < 0
+	})
+	return b.matches
+}
+
+func (b *boundedMatches) Len() int { return len(b.matches) }
+
+func (b *boundedMatches) Less(i, j int) bool {
+	return b.sorter.compare(b.matches[i], b.matches[j]) > 0
+}
+
+func (b *boundedMatches) Swap(i, j int) { b.matches[i], b.matches[j] = b.matches[j], b.matches[i] }
+
+func (b *boundedMatches) Push(x interface{}) { b.matches = append(b.matches, x.(objectMatch)) }
+
+func (b *boundedMatches) Pop() interface{} {
+	match := b.matches[len(b.matches)-1]
+	b.matches = b.matches[:len(b.matches)-1]
+	return match
+}
@@ -0,0 +1,42 @@
+package service
+
+import "testing"
+
+func TestNaturalCompare(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"frame_2", "frame_10", -1},
+		{"frame_10", "frame_2", 1},
+		{"frame_02", "frame_2", -1},
+		{"img", "img_1", -1},
+		{"b1", "a2", 1},
+		{"run_e3/x", "run_e3/x", 0},
+	}
+	for _, tc := range cases {
+		if got := naturalCompare(tc.a, tc.b); got != tc.want {
+			t.Errorf("naturalCompare(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestNaturalPrefixOrder(t *testing.T) {
+	cases := []struct {
+		prefix, s string
+		want      int
+	}{
+		{"runs/e1/", "runs/e2/img_1.jpg", -1},
+		{"runs/e10/", "runs/e2/img_1.jpg", 1},
+		{"runs/e2/", "runs/e2/img_1.jpg", 0},
+		// frame_1 may continue as frame_10, which sorts after frame_2.
+		{"runs/e2/frame_1", "runs/e2/frame_2.png", 0},
+		{"runs/e2/frame_", "runs/e2/frame_2.png", 0},
+		{"a/", "b", -1},
+	}
+	for _, tc := range cases {
+		if got := naturalPrefixOrder(tc.prefix, tc.s); got != tc.want {
+			t.Errorf("naturalPrefixOrder(%q, %q) = %d, want %d", tc.prefix, tc.s, got, tc.want)
+		}
+	}
+}
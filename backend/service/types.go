@@ -1,6 +1,11 @@
 package service
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+
+	"github.com/worldlabs/image-grid-viewer/backend/storage"
+)
 
 // Mode represents the supported pattern modes.
 type Mode string
@@ -8,6 +13,7 @@ type Mode string
 const (
 	ModePercent Mode = "percent"
 	ModeRegex   Mode = "regex"
+	ModeGlob    Mode = "glob"
 )
 
 func ParseMode(value string) (Mode, error) {
@@ -16,6 +22,8 @@ func ParseMode(value string) (Mode, error) {
 		return ModePercent, nil
 	case ModeRegex:
 		return ModeRegex, nil
+	case ModeGlob:
+		return ModeGlob, nil
 	case "":
 		return ModePercent, nil
 	default:
@@ -29,13 +37,76 @@ type QueryRequest struct {
 	Mode     string `json:"mode"`
 	PageSize int    `json:"pageSize"`
 	Cursor   string `json:"cursor"`
+	// IncludeMetadata adds size, content type, timestamps and checksums to
+	// every item.
+	IncludeMetadata bool `json:"includeMetadata"`
+	// Sort orders the results by the given keys in turn. Without it, items
+	// come in traversal order, which is stable but not meaningful.
+	Sort []SortKey `json:"sort,omitempty"`
+	// Filters restrict capture values by capture name. They are checked as
+	// soon as a capture is bound, so rejected directories are not listed.
+	Filters map[string]CaptureFilter `json:"filters,omitempty"`
+	// Where is a CEL expression over the captures and object metadata
+	// that matches must satisfy.
+	Where string `json:"where,omitempty"`
+}
+
+// CaptureFilter restricts the values of one capture. A value must pass every
+// condition that is set; text conditions compare the captured text, and
+// Min and Max are inclusive numeric bounds.
+type CaptureFilter struct {
+	Equals *string  `json:"equals,omitempty"`
+	In     []string `json:"in,omitempty"`
+	NotIn  []string `json:"notIn,omitempty"`
+	Prefix string   `json:"prefix,omitempty"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+}
+
+// SortObject is the sort key for the object name.
+const SortObject = "object"
+
+// SortKey orders results by a capture, or by the object name when By is
+// SortObject. Strings compare in natural order, so img2 sorts before img10;
+// typed captures compare by value.
+type SortKey struct {
+	By   string `json:"by"`
+	Desc bool   `json:"desc,omitempty"`
 }
 
 // QueryItem represents a single matched object.
 type QueryItem struct {
-	Object   string            `json:"object"`
-	URL      string            `json:"url"`
-	Captures map[string]string `json:"captures"`
+	Object   string                 `json:"object"`
+	URL      string                 `json:"url"`
+	ThumbURL string                 `json:"thumbUrl"`
+	Captures map[string]interface{} `json:"captures"`
+	Metadata *ObjectMetadata        `json:"metadata,omitempty"`
+}
+
+// ObjectMetadata carries the storage attributes of a matched object.
+// Attributes the backend does not report are omitted.
+type ObjectMetadata struct {
+	Size        int64      `json:"size"`
+	ContentType string     `json:"contentType,omitempty"`
+	Updated     *time.Time `json:"updated,omitempty"`
+	MD5         string     `json:"md5,omitempty"`
+	CRC32C      string     `json:"crc32c,omitempty"`
+	Generation  int64      `json:"generation,omitempty"`
+}
+
+func newObjectMetadata(obj storage.Object) *ObjectMetadata {
+	meta := &ObjectMetadata{
+		Size:        obj.Size,
+		ContentType: obj.ContentType,
+		MD5:         obj.MD5,
+		CRC32C:      obj.CRC32C,
+		Generation:  obj.Generation,
+	}
+	if !obj.Updated.IsZero() {
+		updated := obj.Updated
+		meta.Updated = &updated
+	}
+	return meta
 }
 
 // QueryStats exposes diagnostic information.
@@ -43,14 +114,36 @@ type QueryStats struct {
 	ScannedPrefixes int `json:"scannedPrefixes"`
 	ScannedObjects  int `json:"scannedObjects"`
 	Matched         int `json:"matched"`
+	// Retries counts storage calls that were retried after transient
+	// failures; a high value means the bucket is throttling us.
+	Retries int `json:"retries"`
+	// CacheHits and CacheMisses count listings answered from, or added to,
+	// the listing cache.
+	CacheHits   int `json:"cacheHits"`
+	CacheMisses int `json:"cacheMisses"`
+	// PrunedPrefixes counts listed prefixes and enumerated values skipped
+	// because a filter rejected a capture they bind.
+	PrunedPrefixes int `json:"prunedPrefixes"`
+}
+
+func (s *QueryStats) add(other QueryStats) {
+	s.ScannedPrefixes += other.ScannedPrefixes
+	s.ScannedObjects += other.ScannedObjects
+	s.Matched += other.Matched
+	s.Retries += other.Retries
+	s.CacheHits += other.CacheHits
+	s.CacheMisses += other.CacheMisses
+	s.PrunedPrefixes += other.PrunedPrefixes
 }
 
 // QueryResponse is the handler response payload.
 type QueryResponse struct {
-	CaptureNames []string    `json:"captureNames"`
-	Items        []QueryItem `json:"items"`
-	NextCursor   *string     `json:"nextCursor,omitempty"`
-	Stats        QueryStats  `json:"stats"`
+	CaptureNames []string `json:"captureNames"`
+	// CaptureTypes maps every capture name to the type of its values.
+	CaptureTypes map[string]CaptureKind `json:"captureTypes"`
+	Items        []QueryItem            `json:"items"`
+	NextCursor   *string                `json:"nextCursor,omitempty"`
+	Stats        QueryStats             `json:"stats"`
 }
 
 // CountResponse returns total matches for a given pattern.
@@ -58,3 +151,83 @@ type CountResponse struct {
 	Total int        `json:"total"`
 	Stats QueryStats `json:"stats"`
 }
+
+// FacetsRequest asks for the distinct values of a pattern's captures.
+type FacetsRequest struct {
+	QueryRequest
+	// Captures limits the facets to these captures; all by default.
+	Captures []string `json:"captures,omitempty"`
+}
+
+// FacetsResponse is the /api/facets response payload.
+type FacetsResponse struct {
+	Facets []Facet    `json:"facets"`
+	Total  int        `json:"total"`
+	Stats  QueryStats `json:"stats"`
+}
+
+// Facet lists the distinct values of one capture in value order.
+type Facet struct {
+	Capture string       `json:"capture"`
+	Type    CaptureKind  `json:"type"`
+	Values  []FacetValue `json:"values"`
+	// Truncated is set when the capture has more distinct values than are
+	// tracked; matches of the untracked values are not counted.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// FacetValue is a capture value and the number of matches that have it.
+type FacetValue struct {
+	Value interface{} `json:"value"`
+	Count int         `json:"count"`
+}
+
+// SegmentListing describes how a segment is traversed.
+type SegmentListing string
+
+const (
+	// ListingLiteral segments are joined into the prefix without listing.
+	ListingLiteral SegmentListing = "literal"
+	// ListingDelimited segments are listed one directory level at a time.
+	ListingDelimited SegmentListing = "delimited"
+	// ListingExpanded segments have known values that are visited directly.
+	ListingExpanded SegmentListing = "expanded"
+	// ListingFlat segments are matched from a flat listing of every object
+	// below the prefix.
+	ListingFlat SegmentListing = "flat"
+)
+
+// ExplainResponse describes how a pattern would be traversed.
+type ExplainResponse struct {
+	Mode          Mode                   `json:"mode"`
+	Scheme        string                 `json:"scheme"`
+	Bucket        string                 `json:"bucket"`
+	CaptureNames  []string               `json:"captureNames"`
+	CaptureTypes  map[string]CaptureKind `json:"captureTypes"`
+	LiteralPrefix string                 `json:"literalPrefix"`
+	Matcher       string                 `json:"matcher"`
+	Segments      []ExplainSegment       `json:"segments"`
+	// InitialJobs lists at most the first 100 of InitialJobCount listings.
+	InitialJobs     []ExplainJob `json:"initialJobs"`
+	InitialJobCount int          `json:"initialJobCount"`
+	Warnings        []string     `json:"warnings"`
+}
+
+// ExplainSegment describes one traversed path segment.
+type ExplainSegment struct {
+	Index           int            `json:"index"`
+	Raw             string         `json:"raw"`
+	Regex           string         `json:"regex"`
+	CaptureNames    []string       `json:"captureNames"`
+	LiteralPrefixes []string       `json:"literalPrefixes"`
+	Values          int            `json:"values,omitempty"`
+	Listing         SegmentListing `json:"listing"`
+}
+
+// ExplainJob is a listing the traversal starts with.
+type ExplainJob struct {
+	Kind         string `json:"kind"`
+	SegmentIndex int    `json:"segmentIndex"`
+	Prefix       string `json:"prefix"`
+	Delimiter    string `json:"delimiter,omitempty"`
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+
+	"github.com/worldlabs/image-grid-viewer/backend/storage"
+)
+
+var celIdentifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// metadataVariables are the object attributes a where expression can use,
+// unless a capture of the same name shadows them.
+var metadataVariables = []struct {
+	name  string
+	typ   *cel.Type
+	value func(storage.Object) interface{}
+}{
+	{"object", cel.StringType, func(o storage.Object) interface{} { return o.Name }},
+	{"size", cel.IntType, func(o storage.Object) interface{} { return o.Size }},
+	{"contentType", cel.StringType, func(o storage.Object) interface{} { return o.ContentType }},
+	{"updated", cel.TimestampType, func(o storage.Object) interface{} { return o.Updated }},
+	{"md5", cel.StringType, func(o storage.Object) interface{} { return o.MD5 }},
+	{"crc32c", cel.StringType, func(o storage.Object) interface{} { return o.CRC32C }},
+	{"generation", cel.IntType, func(o storage.Object) interface{} { return o.Generation }},
+}
+
+// whereProgram is a where expression compiled against a pattern's captures.
+type whereProgram struct {
+	program cel.Program
+	// captures are the captures the expression can refer to, and metadata
+	// the indexes of the metadata variables not shadowed by one.
+	captures []string
+	metadata []int
+	types    map[string]captureType
+}
+
+// whereCacheSize bounds the compiled where expressions a QueryService keeps.
+const whereCacheSize = 64
+
+// whereKey identifies a where expression compiled against a pattern.
+type whereKey struct {
+	pattern string
+	mode    Mode
+	expr    string
+}
+
+// compileWhere type-checks a where expression. Captures are declared with
+// their types and object metadata next to them; it returns nil for an
+// empty expression.
+func compileWhere(cp *compiledPattern, expr string) (*whereProgram, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, nil
+	}
+
+	where := &whereProgram{types: cp.CaptureTypes}
+	var opts []cel.EnvOption
+	kinds := cp.captureKinds()
+	for _, name := range cp.CaptureNames {
+		// Glob captures ($1, $2, ...) are not identifiers.
+		if !celIdentifierRegex.MatchString(name) {
+			continue
+		}
+		opts = append(opts, cel.Variable(name, celType(kinds[name])))
+		where.captures = append(where.captures, name)
+	}
+	for i, v := range metadataVariables {
+		if containsString(where.captures, v.name) {
+			continue
+		}
+		opts = append(opts, cel.Variable(v.name, v.typ))
+		where.metadata = append(where.metadata, i)
+	}
+
+	env, err := cel.NewEnv(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("where: %w", err)
+	}
+	ast, issues := env.Compile(expr)
+	if issues.Err() != nil {
+		return nil, fmt.Errorf("where: %w", issues.Err())
+	}
+	if !ast.OutputType().IsExactType(cel.BoolType) {
+		return nil, fmt.Errorf("where: expression must be a bool, got %s", ast.OutputType())
+	}
+	where.program, err = env.Program(ast, cel.EvalOptions(cel.OptPartialEval))
+	if err != nil {
+		return nil, fmt.Errorf("where: %w", err)
+	}
+	return where, nil
+}
+
+func celType(kind CaptureKind) *cel.Type {
+	switch kind {
+	case CaptureInt:
+		return cel.IntType
+	case CaptureFloat:
+		return cel.DoubleType
+	case CaptureDate:
+		return cel.TimestampType
+	}
+	return cel.StringType
+}
+
+// admits evaluates the expression for a matched object. Evaluation errors,
+// such as int() of a non-numeric capture, reject the object.
+func (w *whereProgram) admits(obj storage.Object, captures map[string]interface{}) bool {
+	if w == nil {
+		return true
+	}
+	vars := make(map[string]interface{}, len(w.captures)+len(w.metadata))
+	for _, name := range w.captures {
+		vars[name] = captures[name]
+	}
+	for _, i := range w.metadata {
+		vars[metadataVariables[i].name] = metadataVariables[i].value(obj)
+	}
+	out, _, err := w.program.Eval(vars)
+	if err != nil {
+		return false
+	}
+	admitted, ok := out.Value().(bool)
+	return ok && admitted
+}
+
+// rejects partially evaluates the expression with the captures bound so
+// far, treating the rest and all metadata as unknown. It is true only when
+// the expression is false whatever the unknowns turn out to be.
+func (w *whereProgram) rejects(bound map[string]string) bool {
+	if w == nil {
+		return false
+	}
+	vars := make(map[string]interface{}, len(bound))
+	var unknowns []*cel.AttributePatternType
+	for _, name := range w.captures {
+		raw, ok := bound[name]
+		var value interface{} = raw
+		if ct, typed := w.types[name]; ok && typed {
+			value, ok = ct.convert(raw)
+		}
+		if !ok {
+			unknowns = append(unknowns, cel.AttributePattern(name))
+			continue
+		}
+		vars[name] = value
+	}
+	for _, i := range w.metadata {
+		unknowns = append(unknowns, cel.AttributePattern(metadataVariables[i].name))
+	}
+
+	activation, err := cel.PartialVars(vars, unknowns...)
+	if err != nil {
+		return false
+	}
+	out, _, err := w.program.Eval(activation)
+	if err != nil {
+		return false
+	}
+	admitted, ok := out.Value().(bool)
+	return ok && !admitted
+}
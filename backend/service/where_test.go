@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/worldlabs/image-grid-viewer/backend/storage"
+)
+
+func TestCompileWhere(t *testing.T) {
+	cp, err := parsePattern("gs://bucket/%split%/%class%_%idx:int%.jpg", ModePercent)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	if where, err := compileWhere(cp, "  "); err != nil || where != nil {
+		t.Fatalf("expected no program for an empty expression, got %v %v", where, err)
+	}
+	for _, expr := range []string{"idx + 1", "missing == 1", "idx == 'a'", "split ==", "size > '1'"} {
+		if _, err := compileWhere(cp, expr); err == nil {
+			t.Errorf("%s: expected compile error", expr)
+		}
+	}
+
+	where, err := compileWhere(cp, "class in ['cat', 'dog'] && idx % 10 == 0 && size > 1024")
+	if err != nil {
+		t.Fatalf("compileWhere returned error: %v", err)
+	}
+	big := storage.Object{Name: "train/cat_10.jpg", Size: 4096}
+	if !where.admits(big, map[string]interface{}{"split": "train", "class": "cat", "idx": int64(10)}) {
+		t.Fatal("expected object to be admitted")
+	}
+	if where.admits(storage.Object{Size: 10}, map[string]interface{}{"split": "train", "class": "cat", "idx": int64(10)}) {
+		t.Fatal("expected small object to be rejected")
+	}
+	if where.admits(big, map[string]interface{}{"split": "train", "class": "cow", "idx": int64(10)}) {
+		t.Fatal("expected other class to be rejected")
+	}
+}
+
+func TestWhereRejectsPartiallyBoundCaptures(t *testing.T) {
+	cp, err := parsePattern("gs://bucket/%split%/%exp%/%idx%.jpg", ModePercent)
+	if err != nil {
+		t.Fatalf("parsePattern returned error: %v", err)
+	}
+	where, err := compileWhere(cp, "split == 'val' && exp.startsWith('e') && int(idx) % 10 == 0 && size > 0")
+	if err != nil {
+		t.Fatalf("compileWhere returned error: %v", err)
+	}
+	if !where.rejects(map[string]string{"split": "train"}) {
+		t.Fatal("expected a bound split that fails the expression to be rejected")
+	}
+	if where.rejects(map[string]string{"split": "val"}) {
+		t.Fatal("expected undecided expression not to be rejected")
+	}
+	if !where.rejects(map[string]string{"split": "val", "exp": "x1"}) {
+		t.Fatal("expected failing exp to be rejected")
+	}
+	if where.rejects(map[string]string{"split": "val", "exp": "e1", "idx": "20"}) {
+		t.Fatal("metadata is unknown, so the expression is undecided")
+	}
+}
+
+func TestQueryReusesCompiledWhere(t *testing.T) {
+	objects, _ := renderTree(2, 6)
+	qs, _ := newTestService(objects...)
+	req := QueryRequest{Pattern: "mem://bucket/runs/run_%exp%/img_%idx:int%.jpg", PageSize: 3, Where: "idx % 2 == 0"}
+
+	first, err := qs.compileRequest(req)
+	if err != nil {
+		t.Fatalf("compileRequest returned error: %v", err)
+	}
+	page, err := qs.Query(context.Background(), req)
+	if err != nil || page.NextCursor == nil {
+		t.Fatalf("expected a first page with a cursor, got %v", err)
+	}
+	req.Cursor = *page.NextCursor
+	second, err := qs.compileRequest(req)
+	if err != nil {
+		t.Fatalf("compileRequest returned error: %v", err)
+	}
+	if second.Where != first.Where {
+		t.Fatal("expected the second page to reuse the compiled expression")
+	}
+
+	if other, err := qs.compileRequest(QueryRequest{Pattern: req.Pattern, Where: "idx % 2 == 1"}); err != nil || other.Where == first.Where {
+		t.Fatalf("expected another expression to compile separately, got %v", err)
+	}
+}
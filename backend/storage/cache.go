@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CachePolicy configures CachingClient.
+type CachePolicy struct {
+	// TTL bounds how long a listing page is served from memory.
+	TTL time.Duration
+	// MaxEntries bounds the number of cached pages; the least recently used
+	// page is dropped first.
+	MaxEntries int
+	// MaxObjects bounds the objects and prefixes held across all cached
+	// pages, evicting like MaxEntries. Zero leaves it unbounded.
+	MaxObjects int
+	// Timeout bounds a shared listing, which no caller can cancel.
+	// Zero means defaultCacheTimeout.
+	Timeout time.Duration
+}
+
+const defaultCacheTimeout = time.Minute
+
+// CachingClient wraps a Client and keeps recent listing pages in memory, so
+// that paging through a query and counting the same pattern list each prefix
+// once. Identical calls in flight at the same time share one request, which
+// no single caller can cancel for the others; it is bounded by the policy's
+// Timeout instead. Reads are passed through.
+type CachingClient struct {
+	next   Client
+	policy CachePolicy
+	now    func() time.Time
+	group  singleflight.Group
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[ListRequest]*list.Element
+	objects int // objects and prefixes across entries
+}
+
+type cachedPage struct {
+	req     ListRequest
+	resp    ListResponse
+	expires time.Time
+}
+
+func NewCachingClient(next Client, policy CachePolicy) *CachingClient {
+	if policy.MaxEntries < 1 {
+		policy.MaxEntries = 1
+	}
+	if policy.Timeout <= 0 {
+		policy.Timeout = defaultCacheTimeout
+	}
+	return &CachingClient{
+		next:    next,
+		policy:  policy,
+		now:     time.Now,
+		order:   list.New(),
+		entries: map[ListRequest]*list.Element{},
+	}
+}
+
+// List serves the page from the cache when possible. Responses report one
+// cache hit or miss; retries are only reported by the call that listed.
+func (c *CachingClient) List(ctx context.Context, req ListRequest) (*ListResponse, error) {
+	if resp, ok := c.lookup(req); ok {
+		resp.CacheHits = 1
+		return resp, nil
+	}
+
+	// The listing is shared, so it runs detached from the caller that
+	// started it; every caller stops waiting when its own ctx ends.
+	listed := false
+	ch := c.group.DoChan(listKey(req), func() (interface{}, error) {
+		listed = true
+		listCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.policy.Timeout)
+		defer cancel()
+		resp, err := c.next.List(listCtx, req)
+		if err != nil {
+			return nil, err
+		}
+		c.store(req, resp)
+		return resp, nil
+	})
+	var result singleflight.Result
+	select {
+	case result = <-ch:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	resp := *result.Val.(*ListResponse)
+	if listed {
+		resp.CacheMisses = 1
+	} else {
+		resp.Retries = 0
+		resp.CacheHits = 1
+	}
+	return &resp, nil
+}
+
+// Read passes through to the wrapped client.
+func (c *CachingClient) Read(ctx context.Context, req ReadRequest) (*ObjectReader, error) {
+	return c.next.Read(ctx, req)
+}
+
+// Stat passes through to the wrapped client.
+func (c *CachingClient) Stat(ctx context.Context, req StatRequest) (*Object, error) {
+	return c.next.Stat(ctx, req)
+}
+
+// Unwrap returns the decorated client.
+func (c *CachingClient) Unwrap() Client {
+	return c.next
+}
+
+func (c *CachingClient) lookup(req ListRequest) (*ListResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[req]
+	if !ok {
+		return nil, false
+	}
+	page := elem.Value.(*cachedPage)
+	if !c.now().Before(page.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	resp := page.resp
+	resp.Retries = 0
+	return &resp, true
+}
+
+func (c *CachingClient) store(req ListRequest, resp *ListResponse) {
+	page := &cachedPage{req: req, resp: *resp, expires: c.now().Add(c.policy.TTL)}
+	page.resp.CacheHits, page.resp.CacheMisses = 0, 0
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[req]; ok {
+		c.remove(elem)
+	}
+	c.entries[req] = c.order.PushFront(page)
+	c.objects += page.size()
+	// A page larger than MaxObjects on its own is not kept either.
+	for c.order.Len() > c.policy.MaxEntries || c.policy.MaxObjects > 0 && c.objects > c.policy.MaxObjects {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops a cached page; c.mu must be held.
+func (c *CachingClient) remove(elem *list.Element) {
+	page := c.order.Remove(elem).(*cachedPage)
+	delete(c.entries, page.req)
+	c.objects -= page.size()
+}
+
+func (p *cachedPage) size() int {
+	return len(p.resp.Objects) + len(p.resp.Prefixes)
+}
+
+func listKey(req ListRequest) string {
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%d", req.Bucket, req.Prefix, req.Delimiter, req.PageToken, req.PageSize)
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCachingClientServesRepeatedListings(t *testing.T) {
+	mem := NewMemoryClient()
+	mem.AddObjects("b", "r/1.jpg", "r/2.jpg")
+	client := NewCachingClient(mem, CachePolicy{TTL: time.Minute, MaxEntries: 10})
+	now := time.Unix(0, 0)
+	client.now = func() time.Time { return now }
+
+	req := ListRequest{Bucket: "b", Prefix: "r/"}
+	first, err := client.List(context.Background(), req)
+	if err != nil || first.CacheMisses != 1 || first.CacheHits != 0 {
+		t.Fatalf("expected miss, got %+v %v", first, err)
+	}
+	second, err := client.List(context.Background(), req)
+	if err != nil || second.CacheHits != 1 || len(second.Objects) != 2 {
+		t.Fatalf("expected hit, got %+v %v", second, err)
+	}
+	if got := len(mem.Requests()); got != 1 {
+		t.Fatalf("expected 1 listing, got %d", got)
+	}
+
+	now = now.Add(time.Minute)
+	if resp, _ := client.List(context.Background(), req); resp.CacheMisses != 1 {
+		t.Fatalf("expected expired entry to miss, got %+v", resp)
+	}
+	if got := len(mem.Requests()); got != 2 {
+		t.Fatalf("expected 2 listings, got %d", got)
+	}
+}
+
+func TestCachingClientEvictsAndSkipsErrors(t *testing.T) {
+	mem := NewMemoryClient()
+	mem.AddObjects("b", "a/1.jpg", "b/1.jpg")
+	mem.InjectFault(Fault{Prefix: "c/", StatusCode: http.StatusServiceUnavailable, Times: 1})
+	client := NewCachingClient(mem, CachePolicy{TTL: time.Minute, MaxEntries: 1})
+
+	for _, prefix := range []string{"a/", "b/", "a/"} {
+		client.List(context.Background(), ListRequest{Bucket: "b", Prefix: prefix})
+	}
+	if got := len(mem.Requests()); got != 3 {
+		t.Fatalf("expected the evicted page to be listed again, got %d listings", got)
+	}
+
+	if _, err := client.List(context.Background(), ListRequest{Bucket: "b", Prefix: "c/"}); err == nil {
+		t.Fatal("expected injected error")
+	}
+	if _, err := client.List(context.Background(), ListRequest{Bucket: "b", Prefix: "c/"}); err != nil {
+		t.Fatalf("error was cached: %v", err)
+	}
+}
+
+func TestCachingClientDeduplicatesConcurrentCalls(t *testing.T) {
+	mem := NewMemoryClient()
+	mem.AddObjects("b", "r/1.jpg")
+	mem.InjectFault(Fault{Latency: 50 * time.Millisecond})
+	client := NewCachingClient(mem, CachePolicy{TTL: time.Minute, MaxEntries: 10})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	hits, misses := 0, 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.List(context.Background(), ListRequest{Bucket: "b", Prefix: "r/"})
+			if err != nil {
+				t.Errorf("List returned error: %v", err)
+				return
+			}
+			mu.Lock()
+			hits += resp.CacheHits
+			misses += resp.CacheMisses
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if got := len(mem.Requests()); got != 1 {
+		t.Fatalf("expected 1 listing, got %d", got)
+	}
+	if misses != 1 || hits != 7 {
+		t.Fatalf("expected 1 miss and 7 hits, got %d and %d", misses, hits)
+	}
+}
+
+func TestCachingClientSharedCallOutlivesCancelledLeader(t *testing.T) {
+	mem := NewMemoryClient()
+	mem.AddObjects("b", "r/1.jpg")
+	mem.InjectFault(Fault{Latency: 200 * time.Millisecond})
+	client := NewCachingClient(mem, CachePolicy{TTL: time.Minute, MaxEntries: 10})
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := client.List(leaderCtx, ListRequest{Bucket: "b", Prefix: "r/"})
+		leaderErr <- err
+	}()
+	for len(mem.Requests()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	followerErr := make(chan error, 1)
+	go func() {
+		resp, err := client.List(context.Background(), ListRequest{Bucket: "b", Prefix: "r/"})
+		if err == nil && len(resp.Objects) != 1 {
+			t.Errorf("expected 1 object, got %+v", resp.Objects)
+		}
+		followerErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-leaderErr; err != context.Canceled {
+		t.Fatalf("expected the leader to see its own cancellation, got %v", err)
+	}
+	if err := <-followerErr; err != nil {
+		t.Fatalf("follower failed with the leader's cancellation: %v", err)
+	}
+	if got := len(mem.Requests()); got != 1 {
+		t.Fatalf("expected 1 listing, got %d", got)
+	}
+}
+
+func TestCachingClientBoundsCachedObjects(t *testing.T) {
+	mem := NewMemoryClient()
+	mem.AddObjects("b", "a/1.jpg", "a/2.jpg", "b/1.jpg", "c/1.jpg", "c/2.jpg", "c/3.jpg", "c/4.jpg")
+	client := NewCachingClient(mem, CachePolicy{TTL: time.Minute, MaxEntries: 10, MaxObjects: 3})
+	list := func(prefix string) *ListResponse {
+		resp, err := client.List(context.Background(), ListRequest{Bucket: "b", Prefix: prefix})
+		if err != nil {
+			t.Fatalf("List(%s): %v", prefix, err)
+		}
+		return resp
+	}
+
+	list("a/")
+	list("b/")
+	if resp := list("a/"); resp.CacheHits != 1 {
+		t.Fatalf("expected a/ to fit alongside b/, got %+v", resp)
+	}
+	// Two more objects push out the least recently used page, b/.
+	mem.AddObjects("b", "d/1.jpg", "d/2.jpg")
+	list("d/")
+	if resp := list("b/"); resp.CacheMisses != 1 {
+		t.Fatalf("expected b/ to be evicted, got %+v", resp)
+	}
+	// A page larger than the bound is not kept at all.
+	list("c/")
+	if resp := list("c/"); resp.CacheMisses != 1 {
+		t.Fatalf("expected the oversized page to miss, got %+v", resp)
+	}
+	if client.objects > 3 {
+		t.Fatalf("cache holds %d objects, bound is 3", client.objects)
+	}
+}
+
+func TestCachingClientTimesOutSharedCall(t *testing.T) {
+	mem := NewMemoryClient()
+	mem.AddObjects("b", "r/1.jpg")
+	mem.InjectFault(Fault{Latency: time.Second})
+	client := NewCachingClient(mem, CachePolicy{TTL: time.Minute, MaxEntries: 10, Timeout: 20 * time.Millisecond})
+
+	start := time.Now()
+	_, err := client.List(context.Background(), ListRequest{Bucket: "b", Prefix: "r/"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the shared listing to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("shared listing ran for %s", elapsed)
+	}
+}
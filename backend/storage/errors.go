@@ -1,5 +1,116 @@
 package storage
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
 
 var ErrBucketRequired = errors.New("bucket is required")
+
+// Failure classes shared by every backend. Backend errors wrap one of these
+// when they can be classified, so callers can test them with errors.Is.
+var (
+	ErrBucketNotFound   = errors.New("bucket not found")
+	ErrObjectNotFound   = errors.New("object not found")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrRateLimited      = errors.New("rate limited")
+	ErrInvalidPageToken = errors.New("invalid page token")
+	ErrTimeout          = errors.New("storage request timed out")
+	ErrInvalidRange     = errors.New("requested range not satisfiable")
+)
+
+// StatusError reports a non-success HTTP status returned by a storage API.
+type StatusError struct {
+	StatusCode int
+	// Code is the backend's machine-readable reason, e.g. "NoSuchBucket".
+	Code    string
+	Message string
+	// Argument names the request parameter the backend rejected, as GCS
+	// reports it in the error location and S3 in ArgumentName.
+	Argument string
+	// Object names the object a read addressed; it is empty for listings.
+	Object string
+	// RetryAfter is the delay requested by the server, if any.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("storage api error: status=%d code=%s msg=%s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("storage api error: status=%d msg=%s", e.StatusCode, e.Message)
+}
+
+// Unwrap classifies the failure as one of the storage error classes, or
+// returns nil when the status has no specific meaning.
+func (e *StatusError) Unwrap() error {
+	switch e.Code {
+	case "rateLimitExceeded", "userRateLimitExceeded", "SlowDown":
+		// GCS reports some quota errors as 403 and S3 throttles with 503.
+		return ErrRateLimited
+	case "NoSuchBucket":
+		return ErrBucketNotFound
+	case "NoSuchKey":
+		return ErrObjectNotFound
+	}
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrPermissionDenied
+	case http.StatusNotFound:
+		// A listing only fails with 404 when the bucket itself is missing.
+		if e.Object != "" {
+			return ErrObjectNotFound
+		}
+		return ErrBucketNotFound
+	case http.StatusRequestedRangeNotSatisfiable:
+		return ErrInvalidRange
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return ErrTimeout
+	case http.StatusBadRequest:
+		if e.Argument == "pageToken" || e.Argument == "continuation-token" {
+			return ErrInvalidPageToken
+		}
+	}
+	return nil
+}
+
+func newStatusError(resp *http.Response, code, message string) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Code:       code,
+		Message:    message,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter accepts both forms of the Retry-After header: a number of
+// seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// wrapTransportError marks errors from sending a request that were caused by
+// a deadline, leaving everything else untouched.
+func wrapTransportError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestStatusErrorClassification(t *testing.T) {
+	cases := []struct {
+		err  *StatusError
+		want error
+	}{
+		{&StatusError{StatusCode: http.StatusNotFound, Code: "notFound"}, ErrBucketNotFound},
+		{&StatusError{StatusCode: http.StatusNotFound, Code: "NoSuchBucket"}, ErrBucketNotFound},
+		{&StatusError{StatusCode: http.StatusForbidden, Code: "forbidden"}, ErrPermissionDenied},
+		{&StatusError{StatusCode: http.StatusUnauthorized}, ErrPermissionDenied},
+		{&StatusError{StatusCode: http.StatusForbidden, Code: "userRateLimitExceeded"}, ErrRateLimited},
+		{&StatusError{StatusCode: http.StatusServiceUnavailable, Code: "SlowDown"}, ErrRateLimited},
+		{&StatusError{StatusCode: http.StatusTooManyRequests}, ErrRateLimited},
+		{&StatusError{StatusCode: http.StatusGatewayTimeout}, ErrTimeout},
+		{&StatusError{StatusCode: http.StatusBadRequest, Code: "InvalidArgument", Message: "The continuation token provided is incorrect", Argument: "continuation-token"}, ErrInvalidPageToken},
+		{&StatusError{StatusCode: http.StatusBadRequest, Code: "invalid", Message: "Invalid Value", Argument: "pageToken"}, ErrInvalidPageToken},
+		{&StatusError{StatusCode: http.StatusBadRequest, Code: "InvalidToken", Message: "The provided token is malformed or otherwise invalid"}, nil},
+		{&StatusError{StatusCode: http.StatusBadRequest, Code: "invalid", Message: "Invalid argument"}, nil},
+		{&StatusError{StatusCode: http.StatusInternalServerError}, nil},
+	}
+	for _, tc := range cases {
+		if got := tc.err.Unwrap(); got != tc.want {
+			t.Errorf("%v: got %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestTransportTimeoutsAreClassified(t *testing.T) {
+	err := wrapTransportError(context.DeadlineExceeded)
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("deadline not classified as timeout: %v", err)
+	}
+	if err := wrapTransportError(context.Canceled); errors.Is(err, ErrTimeout) {
+		t.Fatalf("cancellation classified as timeout: %v", err)
+	}
+}
+
+func TestMemoryClientTypedErrors(t *testing.T) {
+	client := NewMemoryClient()
+	client.AddObjects("b", "a.jpg")
+
+	if _, err := client.List(context.Background(), ListRequest{Bucket: "missing"}); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("expected ErrBucketNotFound, got %v", err)
+	}
+	if _, err := client.List(context.Background(), ListRequest{Bucket: "b", PageToken: "!"}); !errors.Is(err, ErrInvalidPageToken) {
+		t.Fatalf("expected ErrInvalidPageToken, got %v", err)
+	}
+}
@@ -0,0 +1,340 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const defaultFSPageSize = 1000
+
+// FSClient implements Client over a local or network-mounted filesystem.
+// Object names are absolute slash-separated paths without the leading slash,
+// i.e. the path component of a file:/// URL. Only paths under root are visible.
+type FSClient struct {
+	root     string
+	rootName string
+	// realRoot is root with symlinks resolved; files whose links lead
+	// outside it are hidden.
+	realRoot string
+}
+
+// NewFSClient exposes the directory tree rooted at root.
+func NewFSClient(root string) (*FSClient, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", abs)
+	}
+	realRoot, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	rootName := strings.TrimPrefix(filepath.ToSlash(abs), "/")
+	if rootName != "" {
+		rootName += "/"
+	}
+	return &FSClient{root: abs, rootName: rootName, realRoot: realRoot}, nil
+}
+
+type fsEntry struct {
+	key   string
+	isDir bool
+	info  fs.FileInfo
+}
+
+// List walks the filesystem with the same prefix, delimiter and pagination
+// semantics as the GCS JSON API: results are ordered by full object name,
+// directories collapse into prefixes when Delimiter is "/", and PageSize
+// bounds objects and prefixes combined.
+func (c *FSClient) List(ctx context.Context, req ListRequest) (*ListResponse, error) {
+	if req.Delimiter != "" && req.Delimiter != "/" {
+		return nil, fmt.Errorf("unsupported delimiter: %q", req.Delimiter)
+	}
+
+	after, err := decodePageToken(req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := req.PageSize
+	if limit <= 0 || limit > defaultFSPageSize {
+		limit = defaultFSPageSize
+	}
+
+	var entries []fsEntry
+	switch {
+	case strings.HasPrefix(req.Prefix, c.rootName):
+		dirName := req.Prefix[:strings.LastIndexByte(req.Prefix, '/')+1]
+		if err := checkFSName(dirName); err != nil {
+			return nil, err
+		}
+		if req.Delimiter == "" {
+			_, err = c.walk(ctx, dirName, req.Prefix, after, limit+1, &entries)
+		} else {
+			entries, err = c.listDir(dirName, req.Prefix, after, limit+1)
+		}
+		if err != nil {
+			return nil, err
+		}
+	case strings.HasPrefix(c.rootName, req.Prefix):
+		// The root lies below the requested prefix, so only the path leading
+		// to it is visible.
+		if req.Delimiter == "" {
+			if _, err := c.walk(ctx, c.rootName, c.rootName, after, limit+1, &entries); err != nil {
+				return nil, err
+			}
+		} else {
+			next := strings.IndexByte(c.rootName[len(req.Prefix):], '/')
+			key := c.rootName[:len(req.Prefix)+next+1]
+			if key > after {
+				entries = append(entries, fsEntry{key: key, isDir: true})
+			}
+		}
+	}
+
+	resp := &ListResponse{}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		resp.NextPageToken = encodePageToken(entries[limit-1].key)
+	}
+	for _, entry := range entries {
+		if entry.isDir {
+			resp.Prefixes = append(resp.Prefixes, entry.key)
+		} else {
+			resp.Objects = append(resp.Objects, fsObject(entry.key, entry.info))
+		}
+	}
+	return resp, nil
+}
+
+// Read opens the named file, seeking to the requested range.
+func (c *FSClient) Read(ctx context.Context, req ReadRequest) (*ObjectReader, error) {
+	if !strings.HasPrefix(req.Name, c.rootName) || checkFSName(req.Name) != nil {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, req.Name)
+	}
+	target, err := c.resolve(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(target)
+	if err != nil {
+		return nil, fsError(err, req.Name)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fsError(err, req.Name)
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, req.Name)
+	}
+
+	offset, length, err := req.resolve(info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &ObjectReader{
+		ReadCloser: newLimitedReadCloser(f, length),
+		Object:     fsObject(req.Name, info),
+		Offset:     offset,
+		Length:     length,
+	}, nil
+}
+
+// Stat reports the named file's metadata.
+func (c *FSClient) Stat(ctx context.Context, req StatRequest) (*Object, error) {
+	if !strings.HasPrefix(req.Name, c.rootName) || checkFSName(req.Name) != nil {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, req.Name)
+	}
+	target, err := c.resolve(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, fsError(err, req.Name)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, req.Name)
+	}
+	obj := fsObject(req.Name, info)
+	return &obj, nil
+}
+
+// fsError converts missing and unreadable files into storage errors.
+func fsError(err error, name string) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("%w: %s", ErrPermissionDenied, name)
+	}
+	return err
+}
+
+// walk appends objects under dirName that start with prefix and sort after
+// the page token, in lexicographic name order, until limit entries are found.
+func (c *FSClient) walk(ctx context.Context, dirName, prefix, after string, limit int, out *[]fsEntry) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	children, err := c.readDir(dirName, prefix)
+	if err != nil {
+		return false, err
+	}
+	for _, child := range children {
+		if !child.isDir {
+			if child.key > after {
+				*out = append(*out, child)
+				if len(*out) >= limit {
+					return true, nil
+				}
+			}
+			continue
+		}
+		// Every descendant shares the directory key, so the whole subtree
+		// precedes the page token unless the token lies inside it.
+		if child.key <= after && !strings.HasPrefix(after, child.key) {
+			continue
+		}
+		done, err := c.walk(ctx, child.key, prefix, after, limit, out)
+		if err != nil || done {
+			return done, err
+		}
+	}
+	return false, nil
+}
+
+// listDir returns the direct children of dirName, collapsing directories into
+// prefixes as a "/" delimiter listing would.
+func (c *FSClient) listDir(dirName, prefix, after string, limit int) ([]fsEntry, error) {
+	children, err := c.readDir(dirName, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var entries []fsEntry
+	for _, child := range children {
+		if child.key <= after || !strings.HasPrefix(child.key, prefix) {
+			continue
+		}
+		entries = append(entries, child)
+		if len(entries) >= limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// readDir lists dirName and returns the entries that can contain names
+// starting with prefix, sorted by object name. Directory keys carry a
+// trailing slash so they order the same way their contents would.
+func (c *FSClient) readDir(dirName, prefix string) ([]fsEntry, error) {
+	dirEntries, err := os.ReadDir(c.osPath(dirName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	entries := make([]fsEntry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		name := dirName + de.Name()
+		if de.IsDir() {
+			key := name + "/"
+			if strings.HasPrefix(key, prefix) || strings.HasPrefix(prefix, key) {
+				entries = append(entries, fsEntry{key: key, isDir: true})
+			}
+			continue
+		}
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		var info fs.FileInfo
+		if de.Type()&fs.ModeSymlink != 0 {
+			// Symlinked files are served when they stay inside the root;
+			// symlinked directories are not followed so that walks cannot
+			// loop.
+			target, err := c.resolve(name)
+			if err != nil {
+				continue
+			}
+			info, err = os.Stat(target)
+			if err != nil || info.IsDir() {
+				continue
+			}
+		} else if info, err = de.Info(); err != nil {
+			// The file vanished since the directory was read.
+			continue
+		}
+		entries = append(entries, fsEntry{key: name, info: info})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	return entries, nil
+}
+
+func fsObject(name string, info fs.FileInfo) Object {
+	return Object{
+		Name:        name,
+		Size:        info.Size(),
+		ContentType: mime.TypeByExtension(path.Ext(name)),
+		Updated:     info.ModTime().UTC(),
+		ETag:        fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size()),
+	}
+}
+
+func (c *FSClient) osPath(name string) string {
+	return filepath.FromSlash("/" + name)
+}
+
+// resolve returns the file an object name refers to with symlinks followed,
+// and reports it as missing when a link leads outside the root.
+func (c *FSClient) resolve(name string) (string, error) {
+	target, err := filepath.EvalSymlinks(c.osPath(name))
+	if err != nil {
+		return "", fsError(err, name)
+	}
+	if target != c.realRoot && !strings.HasPrefix(target, ensureTrailingSeparator(c.realRoot)) {
+		return "", fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	}
+	return target, nil
+}
+
+func ensureTrailingSeparator(dir string) string {
+	if strings.HasSuffix(dir, string(filepath.Separator)) {
+		return dir
+	}
+	return dir + string(filepath.Separator)
+}
+
+func checkFSName(name string) error {
+	for _, part := range strings.Split(name, "/") {
+		if part == "." || part == ".." {
+			return fmt.Errorf("invalid object name: %s", name)
+		}
+	}
+	return nil
+}
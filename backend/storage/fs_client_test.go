@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestFSClient(t *testing.T, files ...string) (*FSClient, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(f), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client, err := NewFSClient(dir)
+	if err != nil {
+		t.Fatalf("NewFSClient returned error: %v", err)
+	}
+	return client, strings.TrimPrefix(filepath.ToSlash(dir), "/") + "/"
+}
+
+func TestFSClientListDelimiter(t *testing.T) {
+	client, base := newTestFSClient(t, "a/1.jpg", "a.txt", "b/x/2.jpg", "c.jpg")
+
+	resp, err := client.List(context.Background(), ListRequest{Prefix: base, Delimiter: "/"})
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if want := []string{base + "a/", base + "b/"}; !reflect.DeepEqual(resp.Prefixes, want) {
+		t.Fatalf("prefixes mismatch: %v", resp.Prefixes)
+	}
+	if len(resp.Objects) != 2 || resp.Objects[0].Name != base+"a.txt" || resp.Objects[1].Name != base+"c.jpg" {
+		t.Fatalf("objects mismatch: %v", resp.Objects)
+	}
+	if obj := resp.Objects[1]; obj.Size != int64(len("c.jpg")) || obj.ContentType != "image/jpeg" || obj.Updated.IsZero() {
+		t.Fatalf("metadata mismatch: %+v", obj)
+	}
+}
+
+func TestFSClientListRecursivePaginates(t *testing.T) {
+	client, base := newTestFSClient(t, "a/1.jpg", "a.txt", "a/b/2.jpg", "a-c/3.jpg", "b.jpg")
+
+	var names []string
+	token := ""
+	for {
+		resp, err := client.List(context.Background(), ListRequest{Prefix: base + "a", PageToken: token, PageSize: 2})
+		if err != nil {
+			t.Fatalf("List returned error: %v", err)
+		}
+		if len(resp.Prefixes) != 0 {
+			t.Fatalf("unexpected prefixes: %v", resp.Prefixes)
+		}
+		for _, obj := range resp.Objects {
+			names = append(names, strings.TrimPrefix(obj.Name, base))
+		}
+		if resp.NextPageToken == "" {
+			break
+		}
+		token = resp.NextPageToken
+	}
+
+	want := []string{"a-c/3.jpg", "a.txt", "a/1.jpg", "a/b/2.jpg"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("names mismatch: %v", names)
+	}
+}
+
+func TestFSClientStaysInsideRoot(t *testing.T) {
+	client, base := newTestFSClient(t, "a/1.jpg")
+
+	if _, err := client.List(context.Background(), ListRequest{Prefix: base + "a/../../", Delimiter: "/"}); err == nil {
+		t.Fatal("expected error for parent directory reference")
+	}
+	if _, err := client.Read(context.Background(), ReadRequest{Name: base + "a/../a/1.jpg"}); err == nil {
+		t.Fatal("expected error opening path with parent reference")
+	}
+	if _, err := client.Read(context.Background(), ReadRequest{Name: "etc/passwd"}); err == nil {
+		t.Fatal("expected error opening path outside root")
+	}
+
+	resp, err := client.List(context.Background(), ListRequest{Delimiter: "/"})
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	first := base[:strings.IndexByte(base, '/')+1]
+	if !reflect.DeepEqual(resp.Prefixes, []string{first}) || len(resp.Objects) != 0 {
+		t.Fatalf("expected only the path to the root, got %v %v", resp.Prefixes, resp.Objects)
+	}
+}
+
+func TestFSClientHidesSymlinksLeavingRoot(t *testing.T) {
+	client, base := newTestFSClient(t, "a/1.jpg")
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := client.osPath(base + "a")
+	if err := os.Symlink(outside, filepath.Join(dir, "escape.jpg")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "1.jpg"), filepath.Join(dir, "inside.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Dir(outside), filepath.Join(dir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.List(context.Background(), ListRequest{Prefix: base + "a/"})
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	var names []string
+	for _, obj := range resp.Objects {
+		names = append(names, strings.TrimPrefix(obj.Name, base))
+	}
+	if !reflect.DeepEqual(names, []string{"a/1.jpg", "a/inside.jpg"}) {
+		t.Fatalf("expected the escaping link to be hidden, got %v", names)
+	}
+
+	for _, name := range []string{base + "a/escape.jpg", base + "a/escape/secret.txt"} {
+		if _, err := client.Read(context.Background(), ReadRequest{Name: name}); !errors.Is(err, ErrObjectNotFound) {
+			t.Fatalf("%s: expected ErrObjectNotFound from Read, got %v", name, err)
+		}
+		if _, err := client.Stat(context.Background(), StatRequest{Name: name}); !errors.Is(err, ErrObjectNotFound) {
+			t.Fatalf("%s: expected ErrObjectNotFound from Stat, got %v", name, err)
+		}
+	}
+	reader, err := client.Read(context.Background(), ReadRequest{Name: base + "a/inside.jpg"})
+	if err != nil {
+		t.Fatalf("expected a link inside the root to be served: %v", err)
+	}
+	reader.Close()
+}
+
+func TestFSClientReadRange(t *testing.T) {
+	client, base := newTestFSClient(t, "a/1.jpg")
+
+	reader, err := client.Read(context.Background(), ReadRequest{Name: base + "a/1.jpg", Offset: 2, Length: 3})
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	defer reader.Close()
+	body, _ := io.ReadAll(reader)
+	// newTestFSClient writes each file's own name as its content.
+	if string(body) != "1.j" || reader.Object.Size != int64(len("a/1.jpg")) || reader.Object.ETag == "" {
+		t.Fatalf("unexpected read: %q %+v", body, reader.Object)
+	}
+
+	for _, name := range []string{base + "a", base + "a/2.jpg"} {
+		if _, err := client.Read(context.Background(), ReadRequest{Name: name}); !errors.Is(err, ErrObjectNotFound) {
+			t.Fatalf("%s: expected ErrObjectNotFound, got %v", name, err)
+		}
+	}
+}
+
+func TestFSClientStatMatchesRead(t *testing.T) {
+	client, base := newTestFSClient(t, "a/1.jpg")
+
+	obj, err := client.Stat(context.Background(), StatRequest{Name: base + "a/1.jpg"})
+	if err != nil {
+		t.Fatalf("Stat returned error: %v", err)
+	}
+	reader, err := client.Read(context.Background(), ReadRequest{Name: base + "a/1.jpg"})
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	reader.Close()
+	if *obj != reader.Object {
+		t.Fatalf("Stat %+v differs from Read %+v", *obj, reader.Object)
+	}
+
+	if _, err := client.Stat(context.Background(), StatRequest{Name: base + "a"}); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("expected ErrObjectNotFound for a directory, got %v", err)
+	}
+}
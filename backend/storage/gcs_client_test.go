@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+// newFakeGCSServer answers object listings for a single bucket with two pages
+// and range reads of a/1.jpg.
+func newFakeGCSServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userProject := r.URL.Query().Get("userProject")
+		if userProject == "" {
+			userProject = r.Header.Get("X-Goog-User-Project")
+		}
+		if userProject != "billing" {
+			t.Errorf("userProject mismatch: %q", userProject)
+		}
+		// The JSON API client downloads with alt=media; the SDK reads through
+		// the XML API path.
+		media := r.URL.Path == "/storage/v1/b/renders/o/a/1.jpg" && r.URL.Query().Get("alt") == "media"
+		if media || r.URL.Path == "/renders/a/1.jpg" {
+			if got := r.Header.Get("Range"); got != "bytes=-3" {
+				t.Errorf("range mismatch: %q", got)
+			}
+			w.Header().Set("Content-Range", "bytes 7-9/10")
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Header().Set("X-Goog-Generation", "1714564800000000")
+			w.Header().Set("X-Goog-Hash", "crc32c=yZRlqg==,md5=1B2M2Y8AsgTpgAmY7PhCfg==")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte("789"))
+			return
+		}
+		if r.URL.Query().Get("pageToken") == "stale" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":400,"message":"Invalid Value","errors":[{"reason":"invalid","location":"pageToken","locationType":"parameter"}]}}`))
+			return
+		}
+		if r.URL.Path != "/storage/v1/b/renders/o" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":404,"message":"No such object"}}`))
+			return
+		}
+		payload := map[string]interface{}{
+			"items": []map[string]string{{
+				"name":        "a/1.jpg",
+				"size":        "1024",
+				"contentType": "image/jpeg",
+				"updated":     "2024-05-01T12:00:00.000Z",
+				"md5Hash":     "1B2M2Y8AsgTpgAmY7PhCfg==",
+				"crc32c":      "yZRlqg==",
+				"generation":  "1714564800000000",
+			}},
+			"prefixes":      []string{"a/b/"},
+			"nextPageToken": "page-2",
+		}
+		if r.URL.Query().Get("pageToken") == "page-2" {
+			payload = map[string]interface{}{"items": []map[string]string{{"name": "a/2.jpg"}}}
+		}
+		json.NewEncoder(w).Encode(payload)
+	}))
+}
+
+func TestHTTPClientCustomBaseURL(t *testing.T) {
+	server := newFakeGCSServer(t)
+	defer server.Close()
+
+	client := NewHTTPClient(server.Client(), GCSConfig{BaseURL: EmulatorBaseURL(server.URL), UserProject: "billing"})
+	assertGCSListing(t, client)
+
+	if got, want := client.PublicURL("renders", "a/1 #.jpg"), server.URL+"/storage/v1/b/renders/o/a%2F1%20%23.jpg?alt=media"; got != want {
+		t.Fatalf("public url mismatch: %s", got)
+	}
+}
+
+func TestGCSClientCustomEndpoint(t *testing.T) {
+	server := newFakeGCSServer(t)
+	defer server.Close()
+
+	sdk, err := gcs.NewClient(context.Background(),
+		option.WithEndpoint(server.URL+"/storage/v1/"),
+		option.WithoutAuthentication(),
+		option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatalf("NewClient returned error: %v", err)
+	}
+	defer sdk.Close()
+
+	assertGCSListing(t, NewGCSClient(sdk, GCSConfig{UserProject: "billing"}))
+}
+
+func assertGCSListing(t *testing.T, client Client) {
+	t.Helper()
+	resp, err := client.List(context.Background(), ListRequest{Bucket: "renders", Prefix: "a/", Delimiter: "/", PageSize: 2})
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	want := []Object{{
+		Name:        "a/1.jpg",
+		Size:        1024,
+		ContentType: "image/jpeg",
+		Updated:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		MD5:         "1B2M2Y8AsgTpgAmY7PhCfg==",
+		CRC32C:      "yZRlqg==",
+		Generation:  1714564800000000,
+	}}
+	if !reflect.DeepEqual(resp.Objects, want) {
+		t.Fatalf("objects mismatch: %v", resp.Objects)
+	}
+	if want := []string{"a/b/"}; !reflect.DeepEqual(resp.Prefixes, want) {
+		t.Fatalf("prefixes mismatch: %v", resp.Prefixes)
+	}
+	if resp.NextPageToken != "page-2" {
+		t.Fatalf("expected a single page, got token %q", resp.NextPageToken)
+	}
+
+	reader, err := client.Read(context.Background(), ReadRequest{Bucket: "renders", Name: "a/1.jpg", Offset: -3})
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	body, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(body) != "789" || reader.Offset != 7 || reader.Length != 3 || reader.Object.Size != 10 {
+		t.Fatalf("unexpected read: %q %+v", body, reader)
+	}
+	if reader.Object.ContentType != "image/jpeg" || reader.Object.Generation != 1714564800000000 || reader.Object.CRC32C != "yZRlqg==" {
+		t.Fatalf("metadata mismatch: %+v", reader.Object)
+	}
+	if _, err := client.Read(context.Background(), ReadRequest{Bucket: "renders", Name: "missing.jpg"}); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("expected ErrObjectNotFound, got %v", err)
+	}
+
+	if _, err := client.List(context.Background(), ListRequest{Bucket: "renders", Prefix: "a/", PageToken: "stale"}); !errors.Is(err, ErrInvalidPageToken) {
+		t.Fatalf("expected ErrInvalidPageToken, got %v", err)
+	}
+
+	resp, err = client.List(context.Background(), ListRequest{Bucket: "renders", Prefix: "a/", Delimiter: "/", PageSize: 2, PageToken: resp.NextPageToken})
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if want := []Object{{Name: "a/2.jpg"}}; !reflect.DeepEqual(resp.Objects, want) || resp.NextPageToken != "" {
+		t.Fatalf("second page mismatch: %v %q", resp.Objects, resp.NextPageToken)
+	}
+}
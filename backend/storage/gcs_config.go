@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const defaultGCSBaseURL = "https://storage.googleapis.com/storage/v1"
+
+// GCSConfig holds settings shared by the GCS clients.
+type GCSConfig struct {
+	// BaseURL overrides the JSON API endpoint, e.g. to reach a fake-gcs-server.
+	BaseURL string
+	// UserProject is billed for requests against requester-pays buckets.
+	UserProject string
+	// SigningKey, when set, lets the client issue V4 signed URLs.
+	SigningKey *ServiceAccountKey
+}
+
+// EmulatorBaseURL converts a STORAGE_EMULATOR_HOST value ("host:port" or a
+// full URL) into a JSON API base URL.
+func EmulatorBaseURL(host string) string {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return strings.TrimSuffix(host, "/") + "/storage/v1"
+}
+
+// gcsPublicURL addresses an object for browsers. Objects on the production
+// endpoint are hot-linked; emulators only serve them through the JSON API.
+func gcsPublicURL(baseURL, bucket, name string) string {
+	if baseURL == "" || baseURL == defaultGCSBaseURL {
+		public := url.URL{Scheme: "https", Host: "storage.googleapis.com", Path: "/" + bucket + "/" + name}
+		return public.String()
+	}
+	return fmt.Sprintf("%s/b/%s/o/%s?alt=media", baseURL, bucket, url.PathEscape(name))
+}
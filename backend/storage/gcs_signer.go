@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// MaxSignedURLExpiry is the longest lifetime GCS accepts for V4 signatures.
+const MaxSignedURLExpiry = 7 * 24 * time.Hour
+
+// ErrSigningUnavailable is returned by URLSigner implementations that were
+// built without a signing key.
+var ErrSigningUnavailable = errors.New("url signing is not configured")
+
+// URLSigner is implemented by clients that can issue time-limited URLs
+// granting read access to private objects.
+type URLSigner interface {
+	SignedURL(bucket, name string, expiry time.Duration) (string, error)
+}
+
+// ServiceAccountKey holds the fields of a service-account JSON key needed to
+// sign URLs without calling the IAM API.
+type ServiceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+}
+
+// LoadServiceAccountKey reads a service-account JSON key file.
+func LoadServiceAccountKey(path string) (*ServiceAccountKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var key ServiceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("decode service account key %s: %w", path, err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, fmt.Errorf("service account key %s lacks client_email or private_key", path)
+	}
+	return &key, nil
+}
+
+// gcsSignedURL issues a V4 signed GET URL. Custom base URLs, such as an
+// emulator's, become the host of the signed URL.
+func gcsSignedURL(key *ServiceAccountKey, baseURL, bucket, name string, expiry time.Duration) (string, error) {
+	if key == nil {
+		return "", ErrSigningUnavailable
+	}
+	opts := &storage.SignedURLOptions{
+		GoogleAccessID: key.ClientEmail,
+		PrivateKey:     []byte(key.PrivateKey),
+		Method:         http.MethodGet,
+		Expires:        time.Now().Add(expiry),
+		Scheme:         storage.SigningSchemeV4,
+	}
+	if baseURL != "" && baseURL != defaultGCSBaseURL {
+		base, err := url.Parse(baseURL)
+		if err != nil {
+			return "", err
+		}
+		opts.Hostname = base.Host
+		opts.Insecure = base.Scheme == "http"
+	}
+	return storage.SignedURL(bucket, name, opts)
+}
@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // ListRequest encapsulates a single listing query against GCS.
@@ -19,53 +21,112 @@ type ListRequest struct {
 	PageSize  int
 }
 
-// Object represents the subset of metadata we care about from GCS.
+// Object represents the subset of metadata we care about from GCS. Backends
+// that do not track an attribute leave it zero.
 type Object struct {
-	Name string `json:"name"`
+	Name        string    `json:"name"`
+	Size        int64     `json:"size,string"`
+	ContentType string    `json:"contentType"`
+	Updated     time.Time `json:"updated"`
+	// MD5 and CRC32C are base64-encoded checksums, as reported by GCS.
+	MD5        string `json:"md5Hash"`
+	CRC32C     string `json:"crc32c"`
+	Generation int64  `json:"generation,string"`
+	// ETag is the entity tag without quotes.
+	ETag string `json:"etag"`
 }
 
 // ListResponse mirrors the payload from the JSON API.
 type ListResponse struct {
-	Objects      []Object
-	Prefixes     []string
+	Objects       []Object
+	Prefixes      []string
 	NextPageToken string
+	// Retries counts attempts that failed before this response succeeded.
+	Retries int
+	// CacheHits and CacheMisses record whether a CachingClient answered the
+	// call from memory.
+	CacheHits   int
+	CacheMisses int
 }
 
-// Client exposes the minimal listing interface used by the query service.
+// Client exposes the listing and read operations used by the query service.
 type Client interface {
 	List(ctx context.Context, req ListRequest) (*ListResponse, error)
+	// Read opens an object, or a byte range of it, for streaming. Callers
+	// must close the returned reader.
+	Read(ctx context.Context, req ReadRequest) (*ObjectReader, error)
+	// Stat returns the metadata of an object without opening its content.
+	// The validators it reports match those Read reports for the same
+	// version.
+	Stat(ctx context.Context, req StatRequest) (*Object, error)
+}
+
+// PublicURLer is implemented by clients whose objects browsers can load
+// directly when the bucket allows anonymous reads.
+type PublicURLer interface {
+	PublicURL(bucket, name string) string
 }
 
 // HTTPClient implements Client by calling the public JSON API directly.
+// Requests are anonymous unless httpClient attaches credentials.
 type HTTPClient struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL     string
+	userProject string
+	signingKey  *ServiceAccountKey
+	httpClient  *http.Client
 }
 
-func NewHTTPClient(httpClient *http.Client) *HTTPClient {
+func NewHTTPClient(httpClient *http.Client, cfg GCSConfig) *HTTPClient {
 	if httpClient == nil {
 		httpClient = http.DefaultClient
 	}
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultGCSBaseURL
+	}
 	return &HTTPClient{
-		baseURL:    "https://storage.googleapis.com/storage/v1",
-		httpClient: httpClient,
+		baseURL:     baseURL,
+		userProject: cfg.UserProject,
+		signingKey:  cfg.SigningKey,
+		httpClient:  httpClient,
 	}
 }
 
 type apiResponse struct {
-	Items         []Object `json:"items"`
-	Prefixes      []string `json:"prefixes"`
-	NextPageToken string   `json:"nextPageToken"`
-	Error         *struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
+	Items         []Object  `json:"items"`
+	Prefixes      []string  `json:"prefixes"`
+	NextPageToken string    `json:"nextPageToken"`
+	Error         *apiError `json:"error"`
+}
+
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Errors  []struct {
+		Reason   string `json:"reason"`
+		Location string `json:"location"`
+	} `json:"errors"`
+}
+
+// location names the parameter the error is about, e.g. "pageToken".
+func (e *apiError) location() string {
+	if len(e.Errors) > 0 {
+		return e.Errors[0].Location
+	}
+	return ""
+}
+
+func (e *apiError) reason() string {
+	if len(e.Errors) > 0 {
+		return e.Errors[0].Reason
+	}
+	return ""
 }
 
 // List issues a GET request to the JSON API and decodes the response.
 func (c *HTTPClient) List(ctx context.Context, req ListRequest) (*ListResponse, error) {
 	if req.Bucket == "" {
-		return nil, fmt.Errorf("bucket is required")
+		return nil, ErrBucketRequired
 	}
 
 	values := url.Values{}
@@ -81,6 +142,9 @@ func (c *HTTPClient) List(ctx context.Context, req ListRequest) (*ListResponse,
 	if req.PageSize > 0 {
 		values.Set("maxResults", strconv.Itoa(req.PageSize))
 	}
+	if c.userProject != "" {
+		values.Set("userProject", c.userProject)
+	}
 
 	endpoint := fmt.Sprintf("%s/b/%s/o?%s", c.baseURL, req.Bucket, values.Encode())
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
@@ -90,13 +154,12 @@ func (c *HTTPClient) List(ctx context.Context, req ListRequest) (*ListResponse,
 
 	httpResp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, err
+		return nil, wrapTransportError(err)
 	}
 	defer httpResp.Body.Close()
 
 	if httpResp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(io.LimitReader(httpResp.Body, 1024))
-		return nil, fmt.Errorf("storage api error: status=%d body=%s", httpResp.StatusCode, string(body))
+		return nil, readGCSError(httpResp)
 	}
 
 	var payload apiResponse
@@ -105,12 +168,119 @@ func (c *HTTPClient) List(ctx context.Context, req ListRequest) (*ListResponse,
 	}
 
 	if payload.Error != nil {
-		return nil, fmt.Errorf("storage api error: code=%d msg=%s", payload.Error.Code, payload.Error.Message)
+		return nil, &StatusError{StatusCode: payload.Error.Code, Code: payload.Error.reason(), Message: payload.Error.Message}
 	}
 
 	return &ListResponse{
-		Objects:      payload.Items,
-		Prefixes:     payload.Prefixes,
+		Objects:       payload.Items,
+		Prefixes:      payload.Prefixes,
 		NextPageToken: payload.NextPageToken,
 	}, nil
 }
+
+// Read downloads an object through the JSON API's media endpoint.
+func (c *HTTPClient) Read(ctx context.Context, req ReadRequest) (*ObjectReader, error) {
+	if req.Bucket == "" {
+		return nil, ErrBucketRequired
+	}
+
+	values := url.Values{"alt": {"media"}}
+	if c.userProject != "" {
+		values.Set("userProject", c.userProject)
+	}
+	endpoint := fmt.Sprintf("%s/b/%s/o/%s?%s", c.baseURL, req.Bucket, url.PathEscape(req.Name), values.Encode())
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if spec := req.rangeHeader(); spec != "" {
+		httpReq.Header.Set("Range", spec)
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, wrapTransportError(err)
+	}
+	if httpResp.StatusCode != http.StatusOK && httpResp.StatusCode != http.StatusPartialContent {
+		defer httpResp.Body.Close()
+		statusErr := readGCSError(httpResp)
+		statusErr.Object = req.Name
+		return nil, statusErr
+	}
+
+	reader, err := readHTTPObject(httpResp, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	reader.Object.Generation, _ = strconv.ParseInt(httpResp.Header.Get("X-Goog-Generation"), 10, 64)
+	for _, hash := range httpResp.Header.Values("X-Goog-Hash") {
+		for _, part := range strings.Split(hash, ",") {
+			algorithm, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch algorithm {
+			case "md5":
+				reader.Object.MD5 = value
+			case "crc32c":
+				reader.Object.CRC32C = value
+			}
+		}
+	}
+	return reader, nil
+}
+
+// Stat fetches the object's metadata resource, which carries the same ETag
+// as a media download.
+func (c *HTTPClient) Stat(ctx context.Context, req StatRequest) (*Object, error) {
+	if req.Bucket == "" {
+		return nil, ErrBucketRequired
+	}
+
+	values := url.Values{}
+	if c.userProject != "" {
+		values.Set("userProject", c.userProject)
+	}
+	endpoint := fmt.Sprintf("%s/b/%s/o/%s?%s", c.baseURL, req.Bucket, url.PathEscape(req.Name), values.Encode())
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, wrapTransportError(err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		statusErr := readGCSError(httpResp)
+		statusErr.Object = req.Name
+		return nil, statusErr
+	}
+
+	var obj Object
+	if err := json.NewDecoder(httpResp.Body).Decode(&obj); err != nil {
+		return nil, err
+	}
+	return &obj, nil
+}
+
+// readGCSError decodes the JSON error body of a failed response.
+func readGCSError(httpResp *http.Response) *StatusError {
+	body, _ := io.ReadAll(io.LimitReader(httpResp.Body, 1024))
+	var payload apiResponse
+	if json.Unmarshal(body, &payload) == nil && payload.Error != nil {
+		statusErr := newStatusError(httpResp, payload.Error.reason(), payload.Error.Message)
+		statusErr.Argument = payload.Error.location()
+		return statusErr
+	}
+	return newStatusError(httpResp, "", string(body))
+}
+
+// PublicURL returns the address browsers can load an object from.
+func (c *HTTPClient) PublicURL(bucket, name string) string {
+	return gcsPublicURL(c.baseURL, bucket, name)
+}
+
+// SignedURL returns a V4 signed URL valid for expiry.
+func (c *HTTPClient) SignedURL(bucket, name string, expiry time.Duration) (string, error) {
+	return gcsSignedURL(c.signingKey, c.baseURL, bucket, name, expiry)
+}
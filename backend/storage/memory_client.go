@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMemoryPageSize = 1000
+
+// Fault makes MemoryClient misbehave for listings and reads whose bucket and
+// prefix match, so callers can exercise slow, failing and throttled backends.
+type Fault struct {
+	// Bucket restricts the fault to one bucket; empty matches every bucket.
+	Bucket string
+	// Prefix matches listings whose prefix, and reads whose object name,
+	// starts with it; empty matches all.
+	Prefix string
+	// Latency delays the response, honouring context cancellation.
+	Latency time.Duration
+	// Err is returned instead of a response.
+	Err error
+	// StatusCode, when non-zero, returns a StatusError with that code.
+	StatusCode int
+	// RetryAfter is attached to the StatusError.
+	RetryAfter time.Duration
+	// Truncate caps the number of entries per page, as GCS does when it
+	// returns short pages with a continuation token.
+	Truncate int
+	// Times limits how often the fault fires; zero means always.
+	Times int
+
+	fired int
+}
+
+// MemoryClient implements Client over object names held in memory, emulating
+// the GCS JSON API's ordering, prefix, delimiter and page-token semantics.
+type MemoryClient struct {
+	mu       sync.Mutex
+	buckets  map[string][]string
+	contents map[string]map[string][]byte
+	faults   []*Fault
+	requests []ListRequest
+}
+
+func NewMemoryClient() *MemoryClient {
+	return &MemoryClient{
+		buckets:  map[string][]string{},
+		contents: map[string]map[string][]byte{},
+	}
+}
+
+// LoadMemoryFixture reads a JSON file mapping bucket names to object names:
+//
+//	{"demo": ["renders/exp1/img_00.jpg", "renders/exp1/img_01.jpg"]}
+func LoadMemoryFixture(path string) (*MemoryClient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixture map[string][]string
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("decode fixture %s: %w", path, err)
+	}
+	client := NewMemoryClient()
+	for bucket, names := range fixture {
+		client.AddObjects(bucket, names...)
+	}
+	return client, nil
+}
+
+// AddObjects stores the given object names in bucket.
+func (c *MemoryClient) AddObjects(bucket string, names ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Listings read the slice without holding the lock, so it is replaced
+	// rather than modified in place.
+	existing := c.buckets[bucket]
+	merged := append(make([]string, 0, len(existing)+len(names)), existing...)
+	seen := make(map[string]struct{}, len(existing))
+	for _, name := range existing {
+		seen[name] = struct{}{}
+	}
+	for _, name := range names {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		merged = append(merged, name)
+	}
+	sort.Strings(merged)
+	c.buckets[bucket] = merged
+}
+
+// PutObject stores an object with content. Objects added with AddObjects
+// read as empty.
+func (c *MemoryClient) PutObject(bucket, name string, data []byte) {
+	c.AddObjects(bucket, name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.contents[bucket] == nil {
+		c.contents[bucket] = map[string][]byte{}
+	}
+	c.contents[bucket][name] = append([]byte(nil), data...)
+}
+
+// InjectFault registers a fault. Faults are checked in registration order and
+// the first match applies.
+func (c *MemoryClient) InjectFault(f Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = append(c.faults, &f)
+}
+
+// Requests returns every listing request received so far.
+func (c *MemoryClient) Requests() []ListRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ListRequest(nil), c.requests...)
+}
+
+func (c *MemoryClient) List(ctx context.Context, req ListRequest) (*ListResponse, error) {
+	if req.Bucket == "" {
+		return nil, ErrBucketRequired
+	}
+
+	c.mu.Lock()
+	c.requests = append(c.requests, req)
+	fault := c.matchFault(req.Bucket, req.Prefix)
+	names, ok := c.buckets[req.Bucket]
+	c.mu.Unlock()
+
+	limit := req.PageSize
+	if limit <= 0 || limit > defaultMemoryPageSize {
+		limit = defaultMemoryPageSize
+	}
+	if err := fault.apply(ctx); err != nil {
+		return nil, err
+	}
+	if fault != nil && fault.Truncate > 0 && fault.Truncate < limit {
+		limit = fault.Truncate
+	}
+
+	if !ok {
+		return nil, &StatusError{StatusCode: http.StatusNotFound, Message: "bucket not found: " + req.Bucket}
+	}
+	after, err := decodePageToken(req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ListResponse{}
+	start := sort.SearchStrings(names, req.Prefix)
+	count := 0
+	lastKey := ""
+	for _, name := range names[start:] {
+		if !strings.HasPrefix(name, req.Prefix) {
+			break
+		}
+		key := name
+		isPrefix := false
+		if req.Delimiter != "" {
+			if idx := strings.Index(name[len(req.Prefix):], req.Delimiter); idx != -1 {
+				key = name[:len(req.Prefix)+idx+len(req.Delimiter)]
+				isPrefix = true
+			}
+		}
+		// Names sharing a common prefix are contiguous, so the prefix is
+		// emitted once and skipped entirely on the following page.
+		if key <= after || key == lastKey {
+			continue
+		}
+		if count == limit {
+			resp.NextPageToken = encodePageToken(lastKey)
+			break
+		}
+		if isPrefix {
+			resp.Prefixes = append(resp.Prefixes, key)
+		} else {
+			resp.Objects = append(resp.Objects, Object{Name: name})
+		}
+		lastKey = key
+		count++
+	}
+	return resp, nil
+}
+
+// Read returns the stored content of an object. Faults match reads whose
+// object name starts with the fault prefix.
+func (c *MemoryClient) Read(ctx context.Context, req ReadRequest) (*ObjectReader, error) {
+	if req.Bucket == "" {
+		return nil, ErrBucketRequired
+	}
+
+	c.mu.Lock()
+	fault := c.matchFault(req.Bucket, req.Name)
+	names, ok := c.buckets[req.Bucket]
+	data := c.contents[req.Bucket][req.Name]
+	c.mu.Unlock()
+
+	if err := fault.apply(ctx); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &StatusError{StatusCode: http.StatusNotFound, Message: "bucket not found: " + req.Bucket}
+	}
+	if i := sort.SearchStrings(names, req.Name); i == len(names) || names[i] != req.Name {
+		return nil, &StatusError{StatusCode: http.StatusNotFound, Message: "object not found: " + req.Name, Object: req.Name}
+	}
+
+	offset, length, err := req.resolve(int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectReader{
+		ReadCloser: io.NopCloser(bytes.NewReader(data[offset : offset+length])),
+		Object:     Object{Name: req.Name, Size: int64(len(data))},
+		Offset:     offset,
+		Length:     length,
+	}, nil
+}
+
+// Stat reports the size of an object. Faults apply as they do to Read.
+func (c *MemoryClient) Stat(ctx context.Context, req StatRequest) (*Object, error) {
+	if req.Bucket == "" {
+		return nil, ErrBucketRequired
+	}
+
+	c.mu.Lock()
+	fault := c.matchFault(req.Bucket, req.Name)
+	names, ok := c.buckets[req.Bucket]
+	data := c.contents[req.Bucket][req.Name]
+	c.mu.Unlock()
+
+	if err := fault.apply(ctx); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &StatusError{StatusCode: http.StatusNotFound, Message: "bucket not found: " + req.Bucket}
+	}
+	if i := sort.SearchStrings(names, req.Name); i == len(names) || names[i] != req.Name {
+		return nil, &StatusError{StatusCode: http.StatusNotFound, Message: "object not found: " + req.Name, Object: req.Name}
+	}
+	return &Object{Name: req.Name, Size: int64(len(data))}, nil
+}
+
+// apply delays and fails the call as the fault prescribes; a nil fault
+// does nothing.
+func (f *Fault) apply(ctx context.Context) error {
+	if f == nil {
+		return nil
+	}
+	if f.Latency > 0 {
+		if err := sleepContext(ctx, f.Latency); err != nil {
+			return err
+		}
+	}
+	if f.Err != nil {
+		return f.Err
+	}
+	if f.StatusCode != 0 {
+		return &StatusError{
+			StatusCode: f.StatusCode,
+			Message:    http.StatusText(f.StatusCode),
+			RetryAfter: f.RetryAfter,
+		}
+	}
+	return nil
+}
+
+func (c *MemoryClient) matchFault(bucket, prefix string) *Fault {
+	for _, f := range c.faults {
+		if f.Bucket != "" && f.Bucket != bucket {
+			continue
+		}
+		if !strings.HasPrefix(prefix, f.Prefix) {
+			continue
+		}
+		if f.Times > 0 && f.fired >= f.Times {
+			continue
+		}
+		f.fired++
+		return f
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMemoryClientDelimiterPagination(t *testing.T) {
+	client := NewMemoryClient()
+	client.AddObjects("b", "r/a/1.jpg", "r/a/2.jpg", "r/a.txt", "r/b/1.jpg", "r/c.jpg", "x/1.jpg")
+
+	var objects []Object
+	var prefixes []string
+	token := ""
+	for {
+		resp, err := client.List(context.Background(), ListRequest{Bucket: "b", Prefix: "r/", Delimiter: "/", PageToken: token, PageSize: 1})
+		if err != nil {
+			t.Fatalf("List returned error: %v", err)
+		}
+		if got := len(resp.Objects) + len(resp.Prefixes); got > 1 {
+			t.Fatalf("page exceeds page size: %d entries", got)
+		}
+		objects = append(objects, resp.Objects...)
+		prefixes = append(prefixes, resp.Prefixes...)
+		if resp.NextPageToken == "" {
+			break
+		}
+		token = resp.NextPageToken
+	}
+
+	if want := []string{"r/a/", "r/b/"}; !reflect.DeepEqual(prefixes, want) {
+		t.Fatalf("prefixes mismatch: %v", prefixes)
+	}
+	if want := []Object{{Name: "r/a.txt"}, {Name: "r/c.jpg"}}; !reflect.DeepEqual(objects, want) {
+		t.Fatalf("objects mismatch: %v", objects)
+	}
+}
+
+func TestMemoryClientFaults(t *testing.T) {
+	client := NewMemoryClient()
+	client.AddObjects("b", "r/a/1.jpg", "r/a/2.jpg", "r/b/1.jpg")
+	client.InjectFault(Fault{Prefix: "r/a/", StatusCode: http.StatusTooManyRequests, Times: 1})
+	client.InjectFault(Fault{Prefix: "r/a/", Truncate: 1})
+
+	_, err := client.List(context.Background(), ListRequest{Bucket: "b", Prefix: "r/a/"})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 status error, got %v", err)
+	}
+
+	resp, err := client.List(context.Background(), ListRequest{Bucket: "b", Prefix: "r/a/"})
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(resp.Objects) != 1 || resp.NextPageToken == "" {
+		t.Fatalf("expected truncated page, got %v token=%q", resp.Objects, resp.NextPageToken)
+	}
+
+	resp, err = client.List(context.Background(), ListRequest{Bucket: "b", Prefix: "r/b/"})
+	if err != nil || len(resp.Objects) != 1 || resp.NextPageToken != "" {
+		t.Fatalf("unexpected fault outside prefix: %v %v", resp, err)
+	}
+
+	if got := len(client.Requests()); got != 3 {
+		t.Fatalf("expected 3 recorded requests, got %d", got)
+	}
+}
+
+func TestMemoryClientReadRanges(t *testing.T) {
+	client := NewMemoryClient()
+	client.PutObject("b", "img.jpg", []byte("0123456789"))
+	client.AddObjects("b", "empty.jpg")
+
+	cases := []struct {
+		req        ReadRequest
+		want       string
+		wantOffset int64
+		wantErr    error
+	}{
+		{ReadRequest{Name: "img.jpg"}, "0123456789", 0, nil},
+		{ReadRequest{Name: "img.jpg", Offset: 3, Length: 4}, "3456", 3, nil},
+		{ReadRequest{Name: "img.jpg", Offset: 8, Length: 10}, "89", 8, nil},
+		{ReadRequest{Name: "img.jpg", Offset: -3}, "789", 7, nil},
+		{ReadRequest{Name: "img.jpg", Offset: -30}, "0123456789", 0, nil},
+		{ReadRequest{Name: "img.jpg", Offset: 10}, "", 0, ErrInvalidRange},
+		{ReadRequest{Name: "empty.jpg"}, "", 0, nil},
+		{ReadRequest{Name: "missing.jpg"}, "", 0, ErrObjectNotFound},
+	}
+	for _, tc := range cases {
+		tc.req.Bucket = "b"
+		reader, err := client.Read(context.Background(), tc.req)
+		if tc.wantErr != nil {
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("%+v: expected %v, got %v", tc.req, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v: unexpected error: %v", tc.req, err)
+			continue
+		}
+		body, _ := io.ReadAll(reader)
+		reader.Close()
+		if string(body) != tc.want || reader.Offset != tc.wantOffset || reader.Length != int64(len(tc.want)) {
+			t.Errorf("%+v: got %q at %d+%d", tc.req, body, reader.Offset, reader.Length)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ReadRequest selects an object and optionally a byte range of it.
+type ReadRequest struct {
+	Bucket string
+	Name   string
+	// Offset is where reading starts. A negative offset reads the last
+	// -Offset bytes, like an HTTP suffix range.
+	Offset int64
+	// Length bounds the number of bytes read; zero reads to the end.
+	Length int64
+}
+
+// StatRequest selects an object whose metadata is wanted.
+type StatRequest struct {
+	Bucket string
+	Name   string
+}
+
+// ObjectReader streams the requested bytes of an object.
+type ObjectReader struct {
+	io.ReadCloser
+	// Object describes the whole object; Object.Size is its full length.
+	Object Object
+	// Offset and Length locate the returned bytes within the object.
+	Offset int64
+	Length int64
+}
+
+// rangeHeader formats the requested range as an HTTP Range header value, or
+// returns "" when the whole object is wanted.
+func (r ReadRequest) rangeHeader() string {
+	switch {
+	case r.Offset < 0:
+		return fmt.Sprintf("bytes=%d", r.Offset)
+	case r.Length > 0:
+		return fmt.Sprintf("bytes=%d-%d", r.Offset, r.Offset+r.Length-1)
+	case r.Offset > 0:
+		return fmt.Sprintf("bytes=%d-", r.Offset)
+	}
+	return ""
+}
+
+// resolve clamps the requested range to an object of the given size.
+func (r ReadRequest) resolve(size int64) (offset, length int64, err error) {
+	offset = r.Offset
+	if offset < 0 {
+		offset = max(size+offset, 0)
+	}
+	if offset > size || offset == size && r.Offset != 0 {
+		return 0, 0, ErrInvalidRange
+	}
+	length = size - offset
+	if r.Length > 0 && r.Length < length {
+		length = r.Length
+	}
+	return offset, length, nil
+}
+
+// readHTTPObject wraps a successful download response, reading size, range
+// and the standard metadata headers.
+func readHTTPObject(resp *http.Response, name string) (*ObjectReader, error) {
+	reader := &ObjectReader{
+		ReadCloser: resp.Body,
+		Object: Object{
+			Name:        name,
+			Size:        resp.ContentLength,
+			ContentType: resp.Header.Get("Content-Type"),
+			ETag:        strings.Trim(resp.Header.Get("ETag"), `"`),
+		},
+		Length: resp.ContentLength,
+	}
+	if updated, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		reader.Object.Updated = updated.UTC()
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		start, end, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		reader.Offset = start
+		reader.Length = end - start + 1
+		reader.Object.Size = size
+	}
+	return reader, nil
+}
+
+// parseContentRange parses a "bytes start-end/size" Content-Range value.
+func parseContentRange(value string) (start, end, size int64, err error) {
+	spec, ok := strings.CutPrefix(value, "bytes ")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid content range: %q", value)
+	}
+	span, total, ok1 := strings.Cut(spec, "/")
+	first, last, ok2 := strings.Cut(span, "-")
+	if !ok1 || !ok2 {
+		return 0, 0, 0, fmt.Errorf("invalid content range: %q", value)
+	}
+	start, err1 := strconv.ParseInt(first, 10, 64)
+	end, err2 := strconv.ParseInt(last, 10, 64)
+	size, err3 := strconv.ParseInt(total, 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || start > end || end >= size {
+		return 0, 0, 0, fmt.Errorf("invalid content range: %q", value)
+	}
+	return start, end, size, nil
+}
+
+// limitedReadCloser stops at the end of the requested range but still closes
+// the whole underlying reader.
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func newLimitedReadCloser(rc io.ReadCloser, n int64) io.ReadCloser {
+	return limitedReadCloser{Reader: io.LimitReader(rc, n), Closer: rc}
+}
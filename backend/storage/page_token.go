@@ -0,0 +1,20 @@
+package storage
+
+import "encoding/base64"
+
+// encodePageToken wraps the last name returned on a page into an opaque
+// continuation token for backends that paginate by name.
+func encodePageToken(after string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(after))
+}
+
+func decodePageToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	after, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", ErrInvalidPageToken
+	}
+	return string(after), nil
+}
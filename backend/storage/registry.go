@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var schemeRegex = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+// bucketlessSchemes lists schemes whose URLs address a path directly, like
+// file:///abs/path, rather than an object inside a bucket.
+var bucketlessSchemes = map[string]bool{
+	SchemeFile: true,
+}
+
+// Location identifies an object, or an object pattern, by URL.
+type Location struct {
+	Scheme string
+	Bucket string
+	Path   string
+}
+
+// ParseLocation splits a scheme://bucket/path URL. The path is returned
+// without its leading slash and may contain pattern syntax.
+func ParseLocation(raw string) (Location, error) {
+	sep := strings.Index(raw, "://")
+	if sep == -1 || !schemeRegex.MatchString(raw[:sep]) {
+		return Location{}, fmt.Errorf("pattern must start with a storage scheme such as gs://")
+	}
+	scheme := raw[:sep]
+
+	trimmed := raw[sep+3:]
+	slash := strings.IndexByte(trimmed, '/')
+	if slash == -1 {
+		return Location{}, fmt.Errorf("pattern must include bucket and object path")
+	}
+
+	bucket := trimmed[:slash]
+	if bucketlessSchemes[scheme] && bucket != "" {
+		return Location{}, fmt.Errorf("%s patterns must use an absolute path (%s:///...)", scheme, scheme)
+	}
+	if !bucketlessSchemes[scheme] && bucket == "" {
+		return Location{}, ErrBucketRequired
+	}
+
+	path := strings.TrimPrefix(trimmed[slash+1:], "/")
+	if path == "" {
+		return Location{}, fmt.Errorf("object pattern is required")
+	}
+	return Location{Scheme: scheme, Bucket: bucket, Path: path}, nil
+}
+
+// String formats the location back into URL form.
+func (l Location) String() string {
+	return l.Scheme + "://" + l.Bucket + "/" + l.Path
+}
+
+// Registry maps URL schemes to the clients that serve them, so a single
+// server can browse several backends.
+type Registry struct {
+	mu      sync.RWMutex
+	clients map[string]Client
+}
+
+func NewRegistry() *Registry {
+	return &Registry{clients: map[string]Client{}}
+}
+
+// Register makes client serve URLs with the given scheme, replacing any
+// previous registration.
+func (r *Registry) Register(scheme string, client Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients[scheme] = client
+}
+
+// Lookup returns the client registered for scheme.
+func (r *Registry) Lookup(scheme string) (Client, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	client, ok := r.clients[scheme]
+	return client, ok
+}
+
+// Schemes returns the registered schemes in sorted order.
+func (r *Registry) Schemes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	schemes := make([]string, 0, len(r.clients))
+	for scheme := range r.clients {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
@@ -0,0 +1,52 @@
+package storage
+
+import "testing"
+
+func TestParseLocation(t *testing.T) {
+	cases := []struct {
+		raw     string
+		want    Location
+		wantErr bool
+	}{
+		{raw: "gs://bucket/a/%b%.jpg", want: Location{Scheme: "gs", Bucket: "bucket", Path: "a/%b%.jpg"}},
+		{raw: "mem://fixture//a.jpg", want: Location{Scheme: "mem", Bucket: "fixture", Path: "a.jpg"}},
+		{raw: "file:///data/a.jpg", want: Location{Scheme: "file", Path: "data/a.jpg"}},
+		{raw: "file://host/data/a.jpg", wantErr: true},
+		{raw: "gs:///a.jpg", wantErr: true},
+		{raw: "gs://bucket-only", wantErr: true},
+		{raw: "bucket/a.jpg", wantErr: true},
+		{raw: "GS://bucket/a.jpg", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		got, err := ParseLocation(tc.raw)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got %+v", tc.raw, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.raw, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(SchemeGCS, NewHTTPClient(nil, GCSConfig{}))
+
+	if _, ok := registry.Lookup(SchemeGCS); !ok {
+		t.Fatal("expected gs client")
+	}
+	if _, ok := registry.Lookup(SchemeS3); ok {
+		t.Fatal("unexpected s3 client")
+	}
+	if got := registry.Schemes(); len(got) != 1 || got[0] != SchemeGCS {
+		t.Fatalf("schemes mismatch: %v", got)
+	}
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configures RetryClient.
+type RetryPolicy struct {
+	// MaxAttempts bounds the total number of calls, including the first.
+	MaxAttempts int
+	// InitialBackoff is the upper bound of the first jittered delay; each
+	// further attempt doubles it up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// RetryClient wraps a Client and retries listings that fail with transient
+// errors, using full-jitter exponential backoff and honouring Retry-After.
+type RetryClient struct {
+	next   Client
+	policy RetryPolicy
+	sleep  func(ctx context.Context, d time.Duration) error
+	jitter func(max time.Duration) time.Duration
+}
+
+func NewRetryClient(next Client, policy RetryPolicy) *RetryClient {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.MaxBackoff < policy.InitialBackoff {
+		policy.MaxBackoff = policy.InitialBackoff
+	}
+	return &RetryClient{
+		next:   next,
+		policy: policy,
+		sleep:  sleepContext,
+		jitter: func(max time.Duration) time.Duration {
+			if max <= 0 {
+				return 0
+			}
+			return time.Duration(rand.Int63n(int64(max) + 1))
+		},
+	}
+}
+
+// List calls the wrapped client until it succeeds, fails permanently or runs
+// out of attempts. Successful responses report how many attempts failed.
+func (c *RetryClient) List(ctx context.Context, req ListRequest) (*ListResponse, error) {
+	var resp *ListResponse
+	retries, err := c.do(ctx, func() (err error) {
+		resp, err = c.next.List(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp.Retries += retries
+	return resp, nil
+}
+
+// Read retries opening the object. Failures while streaming the body are
+// left to the caller.
+func (c *RetryClient) Read(ctx context.Context, req ReadRequest) (*ObjectReader, error) {
+	var reader *ObjectReader
+	_, err := c.do(ctx, func() (err error) {
+		reader, err = c.next.Read(ctx, req)
+		return err
+	})
+	return reader, err
+}
+
+// Stat retries the metadata lookup.
+func (c *RetryClient) Stat(ctx context.Context, req StatRequest) (*Object, error) {
+	var obj *Object
+	_, err := c.do(ctx, func() (err error) {
+		obj, err = c.next.Stat(ctx, req)
+		return err
+	})
+	return obj, err
+}
+
+// do runs call until it succeeds, fails permanently or runs out of attempts,
+// and reports how many attempts failed before the last one.
+func (c *RetryClient) do(ctx context.Context, call func() error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil {
+			return attempt - 1, nil
+		}
+		if attempt >= c.policy.MaxAttempts || ctx.Err() != nil || !IsRetryable(err) {
+			return attempt - 1, err
+		}
+		delay, ok := c.backoff(attempt, err)
+		if !ok {
+			return attempt - 1, err
+		}
+		if sleepErr := c.sleep(ctx, delay); sleepErr != nil {
+			return attempt - 1, err
+		}
+	}
+}
+
+// Unwrap returns the decorated client.
+func (c *RetryClient) Unwrap() Client {
+	return c.next
+}
+
+// backoff returns the delay before the next attempt. ok is false when the
+// server asks us to wait longer than MaxBackoff, in which case the error is
+// returned rather than stalling the request.
+func (c *RetryClient) backoff(attempt int, err error) (delay time.Duration, ok bool) {
+	ceiling := c.policy.InitialBackoff
+	for i := 1; i < attempt && ceiling < c.policy.MaxBackoff; i++ {
+		ceiling *= 2
+	}
+	ceiling = min(ceiling, c.policy.MaxBackoff)
+
+	delay = c.jitter(ceiling)
+	if retryAfter := retryAfter(err); retryAfter > delay {
+		if retryAfter > c.policy.MaxBackoff {
+			return 0, false
+		}
+		delay = retryAfter
+	}
+	return delay, true
+}
+
+// IsRetryable reports whether err is likely transient: throttling, server
+// errors, timeouts and dropped connections.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.StatusCode)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func retryAfter(err error) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Unwrap strips decorators such as RetryClient and returns the innermost
+// client, so callers can reach backend-specific capabilities.
+func Unwrap(client Client) Client {
+	for {
+		wrapper, ok := client.(interface{ Unwrap() Client })
+		if !ok {
+			return client
+		}
+		client = wrapper.Unwrap()
+	}
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestRetryClient(next Client, attempts int) (*RetryClient, *[]time.Duration) {
+	client := NewRetryClient(next, RetryPolicy{MaxAttempts: attempts, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second})
+	var delays []time.Duration
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	client.jitter = func(max time.Duration) time.Duration { return max }
+	return client, &delays
+}
+
+func TestRetryClientRetriesTransientErrors(t *testing.T) {
+	mem := NewMemoryClient()
+	mem.AddObjects("b", "a.jpg")
+	mem.InjectFault(Fault{StatusCode: http.StatusServiceUnavailable, Times: 2})
+	client, delays := newTestRetryClient(mem, 4)
+
+	resp, err := client.List(context.Background(), ListRequest{Bucket: "b"})
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if resp.Retries != 2 || len(resp.Objects) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}; !equalDurations(*delays, want) {
+		t.Fatalf("backoff mismatch: %v", *delays)
+	}
+}
+
+func TestRetryClientHonoursRetryAfter(t *testing.T) {
+	mem := NewMemoryClient()
+	mem.AddObjects("b", "a.jpg")
+	mem.InjectFault(Fault{StatusCode: http.StatusTooManyRequests, RetryAfter: 800 * time.Millisecond, Times: 1})
+	client, delays := newTestRetryClient(mem, 2)
+
+	if _, err := client.List(context.Background(), ListRequest{Bucket: "b"}); err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if want := []time.Duration{800 * time.Millisecond}; !equalDurations(*delays, want) {
+		t.Fatalf("expected Retry-After delay, got %v", *delays)
+	}
+}
+
+func TestRetryClientGivesUpWhenRetryAfterExceedsMaxBackoff(t *testing.T) {
+	mem := NewMemoryClient()
+	mem.AddObjects("b", "a.jpg")
+	mem.InjectFault(Fault{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour, Times: 1})
+	client, delays := newTestRetryClient(mem, 4)
+
+	_, err := client.List(context.Background(), ListRequest{Bucket: "b"})
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected rate limited error, got %v", err)
+	}
+	if len(*delays) != 0 || len(mem.Requests()) != 1 {
+		t.Fatalf("expected no retry, slept %v over %d requests", *delays, len(mem.Requests()))
+	}
+}
+
+func TestRetryClientStopsOnPermanentErrors(t *testing.T) {
+	mem := NewMemoryClient()
+	mem.InjectFault(Fault{StatusCode: http.StatusForbidden})
+	client, _ := newTestRetryClient(mem, 4)
+
+	_, err := client.List(context.Background(), ListRequest{Bucket: "b"})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %v", err)
+	}
+	if got := len(mem.Requests()); got != 1 {
+		t.Fatalf("permanent error retried: %d requests", got)
+	}
+}
+
+func TestRetryClientGivesUpAfterMaxAttempts(t *testing.T) {
+	mem := NewMemoryClient()
+	mem.InjectFault(Fault{StatusCode: http.StatusBadGateway})
+	client, _ := newTestRetryClient(mem, 3)
+
+	if _, err := client.List(context.Background(), ListRequest{Bucket: "b"}); err == nil {
+		t.Fatal("expected error")
+	}
+	if got := len(mem.Requests()); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+	if Unwrap(client) != Client(mem) {
+		t.Fatal("Unwrap did not return the wrapped client")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := parseRetryAfter("7", now); got != 7*time.Second {
+		t.Fatalf("seconds form: %v", got)
+	}
+	if got := parseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now); got != 90*time.Second {
+		t.Fatalf("date form: %v", got)
+	}
+	if got := parseRetryAfter("soon", now); got != 0 {
+		t.Fatalf("invalid form: %v", got)
+	}
+}
+
+func equalDurations(a, b []time.Duration) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRetryClientRetriesReads(t *testing.T) {
+	mem := NewMemoryClient()
+	mem.PutObject("b", "img.jpg", []byte("data"))
+	mem.InjectFault(Fault{Prefix: "img", StatusCode: http.StatusServiceUnavailable, Times: 2})
+	client, delays := newTestRetryClient(mem, 3)
+
+	reader, err := client.Read(context.Background(), ReadRequest{Bucket: "b", Name: "img.jpg"})
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	reader.Close()
+	if len(*delays) != 2 {
+		t.Fatalf("expected 2 retries, got %v", *delays)
+	}
+}
@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	maxS3Keys       = 1000
+)
+
+// S3Config describes how to reach an S3-compatible service such as AWS S3 or
+// MinIO. Requests are sent unsigned when no access key is configured.
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	PathStyle       bool
+}
+
+// S3Client implements Client with the ListObjectsV2 API.
+type S3Client struct {
+	endpoint   *url.URL
+	pathStyle  bool
+	signer     *s3Signer
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+func NewS3Client(httpClient *http.Client, cfg S3Config) (*S3Client, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	region := cfg.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint: %s", endpoint)
+	}
+
+	client := &S3Client{
+		endpoint:   parsed,
+		pathStyle:  cfg.PathStyle,
+		httpClient: httpClient,
+		now:        time.Now,
+	}
+	if cfg.AccessKeyID != "" {
+		client.signer = &s3Signer{
+			accessKeyID:     cfg.AccessKeyID,
+			secretAccessKey: cfg.SecretAccessKey,
+			sessionToken:    cfg.SessionToken,
+			region:          region,
+		}
+	}
+	return client, nil
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+type s3Error struct {
+	Code         string `xml:"Code"`
+	Message      string `xml:"Message"`
+	ArgumentName string `xml:"ArgumentName"`
+}
+
+// List issues a ListObjectsV2 request and decodes the XML response.
+func (c *S3Client) List(ctx context.Context, req ListRequest) (*ListResponse, error) {
+	if req.Bucket == "" {
+		return nil, ErrBucketRequired
+	}
+
+	values := url.Values{}
+	values.Set("list-type", "2")
+	if req.Prefix != "" {
+		values.Set("prefix", req.Prefix)
+	}
+	if req.Delimiter != "" {
+		values.Set("delimiter", req.Delimiter)
+	}
+	if req.PageToken != "" {
+		values.Set("continuation-token", req.PageToken)
+	}
+	if req.PageSize > 0 {
+		values.Set("max-keys", strconv.Itoa(min(req.PageSize, maxS3Keys)))
+	}
+
+	httpReq, err := c.newRequest(ctx, http.MethodGet, req.Bucket, "", values)
+	if err != nil {
+		return nil, err
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, wrapTransportError(err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, readS3Error(httpResp)
+	}
+
+	var payload s3ListResult
+	if err := xml.NewDecoder(httpResp.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	resp := &ListResponse{
+		Objects:  make([]Object, 0, len(payload.Contents)),
+		Prefixes: make([]string, 0, len(payload.CommonPrefixes)),
+	}
+	for _, item := range payload.Contents {
+		resp.Objects = append(resp.Objects, Object{
+			Name:    item.Key,
+			Size:    item.Size,
+			Updated: item.LastModified,
+			MD5:     etagMD5(item.ETag),
+			ETag:    strings.Trim(item.ETag, `"`),
+		})
+	}
+	for _, prefix := range payload.CommonPrefixes {
+		resp.Prefixes = append(resp.Prefixes, prefix.Prefix)
+	}
+	if payload.IsTruncated {
+		resp.NextPageToken = payload.NextContinuationToken
+	}
+	return resp, nil
+}
+
+// Read issues a GetObject request, forwarding the byte range.
+func (c *S3Client) Read(ctx context.Context, req ReadRequest) (*ObjectReader, error) {
+	if req.Bucket == "" {
+		return nil, ErrBucketRequired
+	}
+
+	httpReq, err := c.newRequest(ctx, http.MethodGet, req.Bucket, req.Name, url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	// Range is not part of the signature, so it can be added afterwards.
+	if spec := req.rangeHeader(); spec != "" {
+		httpReq.Header.Set("Range", spec)
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, wrapTransportError(err)
+	}
+	if httpResp.StatusCode != http.StatusOK && httpResp.StatusCode != http.StatusPartialContent {
+		defer httpResp.Body.Close()
+		statusErr := readS3Error(httpResp)
+		statusErr.Object = req.Name
+		return nil, statusErr
+	}
+
+	reader, err := readHTTPObject(httpResp, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	reader.Object.MD5 = etagMD5(reader.Object.ETag)
+	return reader, nil
+}
+
+// Stat issues a HeadObject request.
+func (c *S3Client) Stat(ctx context.Context, req StatRequest) (*Object, error) {
+	if req.Bucket == "" {
+		return nil, ErrBucketRequired
+	}
+
+	httpReq, err := c.newRequest(ctx, http.MethodHead, req.Bucket, req.Name, url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, wrapTransportError(err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		defer httpResp.Body.Close()
+		statusErr := readS3Error(httpResp)
+		statusErr.Object = req.Name
+		return nil, statusErr
+	}
+
+	reader, err := readHTTPObject(httpResp, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	reader.Close()
+	reader.Object.MD5 = etagMD5(reader.Object.ETag)
+	return &reader.Object, nil
+}
+
+// readS3Error decodes the XML error body of a failed response.
+func readS3Error(httpResp *http.Response) *StatusError {
+	body, _ := io.ReadAll(io.LimitReader(httpResp.Body, 1024))
+	var apiErr s3Error
+	if xml.Unmarshal(body, &apiErr) == nil && apiErr.Code != "" {
+		statusErr := newStatusError(httpResp, apiErr.Code, apiErr.Message)
+		statusErr.Argument = apiErr.ArgumentName
+		return statusErr
+	}
+	return newStatusError(httpResp, "", string(body))
+}
+
+// etagMD5 returns the base64 MD5 digest carried by the ETag of objects that
+// were uploaded in one part. Multipart ETags are not digests of the content.
+func etagMD5(etag string) string {
+	digest, err := hex.DecodeString(strings.Trim(etag, `"`))
+	if err != nil || len(digest) != md5.Size {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(digest)
+}
+
+// PublicURL returns the unsigned address of an object, which browsers can
+// load when the bucket allows anonymous reads.
+func (c *S3Client) PublicURL(bucket, name string) string {
+	return c.objectURL(bucket, name).String()
+}
+
+func (c *S3Client) newRequest(ctx context.Context, method, bucket, key string, values url.Values) (*http.Request, error) {
+	target := c.objectURL(bucket, key)
+	target.RawQuery = values.Encode()
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.signer != nil {
+		c.signer.sign(httpReq, c.now())
+	}
+	return httpReq, nil
+}
+
+// objectURL addresses bucket and key with either path-style
+// (endpoint/bucket/key) or virtual-hosted style (bucket.endpoint/key) URLs.
+func (c *S3Client) objectURL(bucket, key string) *url.URL {
+	target := *c.endpoint
+	basePath := strings.TrimSuffix(target.Path, "/")
+	if c.pathStyle {
+		target.Path = basePath + "/" + bucket + "/" + key
+	} else {
+		target.Host = bucket + "." + target.Host
+		target.Path = basePath + "/" + key
+	}
+	target.RawPath = awsURIEncode(target.Path, false)
+	return &target
+}
@@ -0,0 +1,9 @@
+package storage
+
+// URL schemes of the built-in backends.
+const (
+	SchemeGCS    = "gs"
+	SchemeS3     = "s3"
+	SchemeFile   = "file"
+	SchemeMemory = "mem"
+)
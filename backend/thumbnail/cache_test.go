@@ -0,0 +1,117 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewDiskCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put("a", []byte("aaaa"))
+	cache.Put("b", []byte("bbbb"))
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	cache.Put("c", []byte("cccc"))
+
+	if _, ok := cache.Get("b"); ok {
+		t.Fatal("expected b to be evicted")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("evicted file still on disk: %v", err)
+	}
+	if cache.Size() != 8 {
+		t.Fatalf("unexpected size %d", cache.Size())
+	}
+
+	reopened, err := NewDiskCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := reopened.Get("c"); !ok || string(data) != "cccc" {
+		t.Fatalf("entry lost across restart: %q %v", data, ok)
+	}
+}
+
+func TestThumbnailerRendersOnce(t *testing.T) {
+	cache, err := NewDiskCache(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbs := New(cache, 2)
+	src := encodeTestImage(t, 64, 64, 255, false)
+	key := Key("mem://b/a.jpg", "v1", 32)
+
+	first, err := thumbs.Get(context.Background(), key, 32, openBytes(src))
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	// A hit must not open the source.
+	second, err := thumbs.Get(context.Background(), key, 32, func(context.Context) (io.ReadCloser, error) {
+		t.Fatal("source opened on a cache hit")
+		return nil, nil
+	})
+	if err != nil || !bytes.Equal(first, second) {
+		t.Fatalf("expected cached thumbnail, got err=%v", err)
+	}
+	if Key("mem://b/a.jpg", "v2", 32) == key {
+		t.Fatal("key must change with the object version")
+	}
+}
+
+func TestThumbnailerRenderOutlivesCancelledLeader(t *testing.T) {
+	cache, err := NewDiskCache(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbs := New(cache, 2)
+	src := encodeTestImage(t, 64, 64, 255, false)
+	key := Key("mem://b/a.jpg", "v1", 32)
+
+	opened := make(chan struct{})
+	release := make(chan struct{})
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := thumbs.Get(leaderCtx, key, 32, func(ctx context.Context) (io.ReadCloser, error) {
+			close(opened)
+			<-release
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return io.NopCloser(bytes.NewReader(src)), nil
+		})
+		leaderErr <- err
+	}()
+	<-opened
+
+	cancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled leader to stop waiting, got %v", err)
+	}
+	close(release)
+
+	// The leader's render is still in flight or already cached, so this
+	// caller must not open the source again.
+	data, err := thumbs.Get(context.Background(), key, 32, func(context.Context) (io.ReadCloser, error) {
+		return nil, errors.New("source opened twice")
+	})
+	if err != nil || len(data) == 0 {
+		t.Fatalf("render did not survive the leader's cancellation: %v", err)
+	}
+}
+
+func openBytes(data []byte) func(context.Context) (io.ReadCloser, error) {
+	return func(context.Context) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+}
@@ -0,0 +1,76 @@
+// Package thumbnail renders downscaled previews of images and caches them on
+// local disk.
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// MinWidth and MaxWidth bound the requested thumbnail width.
+	MinWidth = 16
+	MaxWidth = 2048
+
+	maxSourceBytes  = 64 << 20
+	maxSourcePixels = 100_000_000
+	jpegQuality     = 82
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrSourceTooLarge    = errors.New("image is too large to thumbnail")
+)
+
+// Render decodes a JPEG, PNG or GIF image and scales it down to width pixels,
+// keeping the aspect ratio. Images are never scaled up. Opaque images are
+// encoded as JPEG and images with transparency as PNG.
+func Render(src io.Reader, width int) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(src, maxSourceBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSourceBytes {
+		return nil, ErrSourceTooLarge
+	}
+
+	// Check the dimensions before decoding so that a small file cannot
+	// claim a huge canvas.
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width == 0 || cfg.Height == 0 {
+		return nil, ErrUnsupportedFormat
+	}
+	if cfg.Width*cfg.Height > maxSourcePixels {
+		return nil, ErrSourceTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	if width > bounds.Dx() {
+		width = bounds.Dx()
+	}
+	height := max(1, (bounds.Dy()*width+bounds.Dx()/2)/bounds.Dx())
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+
+	var out bytes.Buffer
+	if dst.Opaque() {
+		err = jpeg.Encode(&out, dst, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&out, dst)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
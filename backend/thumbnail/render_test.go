@@ -0,0 +1,67 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func encodeTestImage(t *testing.T, w, h int, alpha uint8, asPNG bool) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: alpha})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	if asPNG {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRenderScalesDown(t *testing.T) {
+	data, err := Render(bytes.NewReader(encodeTestImage(t, 400, 300, 255, false)), 100)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" || cfg.Width != 100 || cfg.Height != 75 {
+		t.Fatalf("got %s %dx%d, want jpeg 100x75", format, cfg.Width, cfg.Height)
+	}
+}
+
+func TestRenderKeepsTransparencyAndNeverUpscales(t *testing.T) {
+	data, err := Render(bytes.NewReader(encodeTestImage(t, 40, 20, 100, true)), 256)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" || cfg.Width != 40 || cfg.Height != 20 {
+		t.Fatalf("got %s %dx%d, want png 40x20", format, cfg.Width, cfg.Height)
+	}
+}
+
+func TestRenderRejectsUnknownFormats(t *testing.T) {
+	if _, err := Render(strings.NewReader("not an image"), 64); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("expected ErrUnsupportedFormat, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package thumbnail
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Thumbnailer renders thumbnails through a DiskCache, rendering each one at
+// most once at a time and bounding how many render concurrently.
+type Thumbnailer struct {
+	cache *DiskCache
+	group singleflight.Group
+	slots chan struct{}
+}
+
+func New(cache *DiskCache, concurrency int) *Thumbnailer {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Thumbnailer{cache: cache, slots: make(chan struct{}, concurrency)}
+}
+
+// Key identifies the thumbnail of one version of an object at a width.
+func Key(object, version string, width int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d", object, version, width)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the thumbnail stored under key, rendering it from the source
+// that open returns on a miss. Concurrent callers for the same key share one
+// render, which opens the source itself and keeps running when the caller
+// that started it goes away; each caller stops waiting when its own ctx ends.
+func (t *Thumbnailer) Get(ctx context.Context, key string, width int, open func(context.Context) (io.ReadCloser, error)) ([]byte, error) {
+	if data, ok := t.cache.Get(key); ok {
+		return data, nil
+	}
+	renderCtx := context.WithoutCancel(ctx)
+	ch := t.group.DoChan(key, func() (interface{}, error) {
+		t.slots <- struct{}{}
+		defer func() { <-t.slots }()
+		src, err := open(renderCtx)
+		if err != nil {
+			return nil, err
+		}
+		defer src.Close()
+		data, err := Render(src, width)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.cache.Put(key, data); err != nil {
+			log.Printf("thumbnail cache write failed: %v", err)
+		}
+		return data, nil
+	})
+	select {
+	case result := <-ch:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.([]byte), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
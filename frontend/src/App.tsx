@@ -5,7 +5,8 @@ import { GridViewport } from './components/GridViewport';
 import { ViewerModal } from './components/ViewerModal';
 import { ColumnSelector } from './components/ColumnSelector';
 import { groupMatches, MatchItem, GroupedResult } from './lib/transform';
-import { QueryMode, QueryResponse, CountResponse } from './types/api';
+import { errorFromResponse } from './lib/apiError';
+import { QueryMode, QueryResponse, CountResponse, ExplainResponse, FacetsResponse } from './types/api';
 import './App.css';
 
 const DEFAULT_PATTERN = 'gs://wlt-public-sandbox/imgrid-takehome/%exp%/%class%_00.jpg';
@@ -34,11 +35,10 @@ export default function App() {
       const response = await fetch('/api/query', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ pattern, mode, pageSize: 120, cursor: pageParam }),
+        body: JSON.stringify({ pattern, mode, pageSize: 120, cursor: pageParam, includeMetadata: true }),
       });
       if (!response.ok) {
-        const body = await response.json().catch(() => ({}));
-        throw new Error(body.error || 'Query failed');
+        throw await errorFromResponse(response, 'Query failed');
       }
       return response.json();
     },
@@ -60,8 +60,7 @@ export default function App() {
         body: JSON.stringify({ pattern, mode, pageSize: 120 }),
       });
       if (!response.ok) {
-        const body = await response.json().catch(() => ({}));
-        throw new Error(body.error || 'Count failed');
+        throw await errorFromResponse(response, 'Count failed');
       }
       return response.json();
     },
@@ -70,7 +69,27 @@ export default function App() {
     refetchOnMount: false,
   });
 
+  // The plan is cheap to compute, so it is fetched alongside the query to
+  // flag patterns that scan the whole bucket.
+  const { data: plan } = useQuery<ExplainResponse>({
+    queryKey: ['explain', pattern, mode],
+    queryFn: async () => {
+      const response = await fetch('/api/explain', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ pattern, mode }),
+      });
+      if (!response.ok) {
+        throw await errorFromResponse(response, 'Explain failed');
+      }
+      return response.json();
+    },
+    enabled: hasSubmitted && !!pattern,
+    staleTime: Infinity,
+  });
+
   const captureNames = data?.pages[0]?.captureNames ?? [];
+  const captureTypes = data?.pages[0]?.captureTypes;
   const matches = useMemo(() => data?.pages.flatMap((page) => page.items) ?? [], [data]);
   const controlsDisabled = captureNames.length === 0 || isLoading;
   const allItemsLoaded = !hasNextPage && !isFetchingNextPage;
@@ -84,10 +103,39 @@ export default function App() {
     }
   }, [captureNames]);
 
-  const { rows, matches: groupedMatches } = useMemo<GroupedResult>(
-    () => groupMatches(matches, groupBy && captureNames.includes(groupBy) ? groupBy : captureNames[0], columns),
-    [matches, groupBy, captureNames, columns]
-  );
+  // Group sizes come from the facets endpoint, so headers show the full
+  // size of each group rather than what the loaded pages contain.
+  const { data: facets } = useQuery<FacetsResponse>({
+    queryKey: ['facets', pattern, mode, groupBy, queryVersion],
+    queryFn: async () => {
+      const response = await fetch('/api/facets', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ pattern, mode, captures: [groupBy] }),
+      });
+      if (!response.ok) {
+        throw await errorFromResponse(response, 'Facets failed');
+      }
+      return response.json();
+    },
+    enabled: hasSubmitted && !!pattern && !!groupBy,
+    staleTime: Infinity,
+    refetchOnMount: false,
+  });
+
+  const groupTotals = useMemo(() => {
+    const facet = facets?.facets.find((f) => f.capture === groupBy);
+    if (!facet || facet.truncated) {
+      return undefined;
+    }
+    return new Map(facet.values.map((v) => [String(v.value), v.count]));
+  }, [facets, groupBy]);
+
+  const { rows, matches: groupedMatches } = useMemo<GroupedResult>(() => {
+    const key = groupBy && captureNames.includes(groupBy) ? groupBy : captureNames[0];
+    const totals = key === groupBy ? groupTotals : undefined;
+    return groupMatches(matches, key, columns, key ? captureTypes?.[key] : undefined, totals);
+  }, [matches, groupBy, captureNames, captureTypes, columns, groupTotals]);
   const totalFiles = groupedMatches.length;
   const captureCount = captureNames.length;
   const previousMatchCountRef = useRef(groupedMatches.length);
@@ -220,6 +268,14 @@ export default function App() {
         <PatternForm value={pattern} mode={mode} onSubmit={handlePatternSubmit} />
       </header>
 
+      {plan && plan.warnings.length > 0 && (
+        <ul className="plan-warnings">
+          {plan.warnings.map((warning) => (
+            <li key={warning}>{warning}</li>
+          ))}
+        </ul>
+      )}
+
       {(captureNames.length > 0 || isLoading) && (
         <section className="results-shell">
           <div className="results-top">
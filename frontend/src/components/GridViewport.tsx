@@ -30,6 +30,8 @@ interface RowProps {
 const ThumbCard = memo(
   ({ item, onSelect, displayLabel }: { item: MatchItem; onSelect: (item: MatchItem) => void; displayLabel: string }) => {
     const [status, setStatus] = useState<'loading' | 'loaded' | 'error'>('loading');
+    // Fall back to the full image when the thumbnail cannot be rendered.
+    const [src, setSrc] = useState(item.thumbUrl ?? item.url);
 
     return (
       <button
@@ -42,11 +44,11 @@ const ThumbCard = memo(
             <span className="thumb-error">Failed to load</span>
           ) : (
             <img
-              src={item.url}
+              src={src}
               alt={item.object}
               loading="lazy"
               onLoad={() => setStatus('loaded')}
-              onError={() => setStatus('error')}
+              onError={() => (src !== item.url ? setSrc(item.url) : setStatus('error'))}
             />
           )}
         </div>
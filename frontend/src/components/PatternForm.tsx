@@ -44,6 +44,7 @@ export function PatternForm({ value, mode, onSubmit }: PatternFormProps) {
         <select id="mode-select" value={currentMode} onChange={(e) => setCurrentMode(e.target.value as QueryMode)}>
           <option value="percent">Percent</option>
           <option value="regex">Regex</option>
+          <option value="glob">Glob</option>
         </select>
       </div>
       <button type="submit">Run Query</button>
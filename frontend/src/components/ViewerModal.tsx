@@ -1,5 +1,6 @@
 import { useEffect, useMemo } from 'react';
 import { MatchItem } from '../lib/transform';
+import { ObjectMetadata } from '../types/api';
 
 interface ViewerModalProps {
   items: MatchItem[];
@@ -84,6 +85,7 @@ export function ViewerModal({
                   ))}
                 </div>
                 <p className="meta path">{selected?.object}</p>
+                {selected?.metadata && <p className="meta">{describeMetadata(selected.metadata)}</p>}
               </>
             )}
           </div>
@@ -130,3 +132,21 @@ export function ViewerModal({
     </div>
   );
 }
+
+function describeMetadata(metadata: ObjectMetadata): string {
+  const parts = [formatBytes(metadata.size)];
+  if (metadata.contentType) parts.push(metadata.contentType);
+  if (metadata.updated) parts.push(new Date(metadata.updated).toLocaleString());
+  return parts.join(' · ');
+}
+
+function formatBytes(size: number): string {
+  const units = ['B', 'KB', 'MB', 'GB'];
+  let value = size;
+  let unit = 0;
+  while (value >= 1024 && unit < units.length - 1) {
+    value /= 1024;
+    unit++;
+  }
+  return `${unit === 0 ? value : value.toFixed(1)} ${units[unit]}`;
+}
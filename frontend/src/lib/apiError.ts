@@ -0,0 +1,16 @@
+import { ApiError, ApiErrorCode } from '../types/api';
+
+const FRIENDLY_MESSAGES: Partial<Record<ApiErrorCode, string>> = {
+  permission_denied: "You don't have access to this bucket.",
+  bucket_not_found: 'Bucket not found. Check the bucket name in the pattern.',
+  rate_limited: 'The storage backend is throttling requests. Try again in a moment.',
+  timeout: 'The storage backend did not respond in time. Try a narrower pattern.',
+};
+
+// Builds an Error from a failed API response, preferring a friendly message
+// for error codes users can act on.
+export async function errorFromResponse(response: Response, fallback: string): Promise<Error> {
+  const body: Partial<ApiError> = await response.json().catch(() => ({}));
+  const friendly = body.code ? FRIENDLY_MESSAGES[body.code] : undefined;
+  return new Error(friendly ?? body.error ?? fallback);
+}
@@ -1,4 +1,4 @@
-import { QueryItem } from '../types/api';
+import { CaptureType, QueryItem } from '../types/api';
 
 export interface MatchItem extends QueryItem {
   groupKey: string;
@@ -15,7 +15,13 @@ export interface GroupedResult {
   matches: MatchItem[];
 }
 
-export function groupMatches(items: QueryItem[], groupBy?: string, columns = 4): GroupedResult {
+export function groupMatches(
+  items: QueryItem[],
+  groupBy?: string,
+  columns = 4,
+  groupType: CaptureType = 'string',
+  groupTotals?: Map<string, number>
+): GroupedResult {
   if (!items.length || !groupBy) {
     return { rows: [], matches: [] };
   }
@@ -23,20 +29,26 @@ export function groupMatches(items: QueryItem[], groupBy?: string, columns = 4):
   const groups = new Map<string, QueryItem[]>();
 
   for (const item of items) {
-    const value = item.captures[groupBy] ?? '—';
+    const value = String(item.captures[groupBy] ?? '—');
     if (!groups.has(value)) {
       groups.set(value, []);
     }
     groups.get(value)!.push(item);
   }
 
-  const sortedKeys = Array.from(groups.keys()).sort((a, b) => a.localeCompare(b));
+  const numeric = groupType === 'int' || groupType === 'float';
+  const sortedKeys = Array.from(groups.keys()).sort((a, b) =>
+    numeric ? Number(a) - Number(b) : a.localeCompare(b)
+  );
   const rows: GridRow[] = [];
   const matches: MatchItem[] = [];
 
   for (const key of sortedKeys) {
-    rows.push({ type: 'header', key: `header-${groupBy}-${key}`, label: `${groupBy}: ${key}` });
     const groupItems = groups.get(key)!;
+    // Totals come from the facets endpoint and cover pages not loaded yet.
+    const total = groupTotals?.get(key);
+    const label = total !== undefined ? `${groupBy}: ${key} (${groupItems.length} of ${total})` : `${groupBy}: ${key}`;
+    rows.push({ type: 'header', key: `header-${groupBy}-${key}`, label });
     const groupMatches: MatchItem[] = [];
 
     for (const item of groupItems) {
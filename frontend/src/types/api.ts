@@ -1,26 +1,67 @@
-export type QueryMode = 'percent' | 'regex';
+export type QueryMode = 'percent' | 'regex' | 'glob';
 
 export interface QueryRequest {
   pattern: string;
   mode: QueryMode;
   pageSize: number;
   cursor?: string | null;
+  includeMetadata?: boolean;
+  sort?: SortKey[];
+  filters?: Record<string, CaptureFilter>;
+  // A CEL expression over captures and object metadata.
+  where?: string;
 }
 
+export interface CaptureFilter {
+  equals?: string;
+  in?: string[];
+  notIn?: string[];
+  prefix?: string;
+  min?: number;
+  max?: number;
+}
+
+// by is a capture name or 'object'.
+export interface SortKey {
+  by: string;
+  desc?: boolean;
+}
+
+export type CaptureType = 'string' | 'int' | 'float' | 'date';
+
+// Dates arrive as RFC 3339 strings.
+export type CaptureValue = string | number;
+
 export interface QueryItem {
   object: string;
   url: string;
-  captures: Record<string, string>;
+  thumbUrl?: string;
+  captures: Record<string, CaptureValue>;
+  metadata?: ObjectMetadata;
+}
+
+export interface ObjectMetadata {
+  size: number;
+  contentType?: string;
+  updated?: string;
+  md5?: string;
+  crc32c?: string;
+  generation?: number;
 }
 
 export interface QueryResponse {
   captureNames: string[];
+  captureTypes?: Record<string, CaptureType>;
   items: QueryItem[];
   nextCursor?: string | null;
   stats?: {
     scannedPrefixes: number;
     scannedObjects: number;
     matched: number;
+    retries?: number;
+    cacheHits?: number;
+    cacheMisses?: number;
+    prunedPrefixes?: number;
   };
 }
 
@@ -30,5 +71,68 @@ export interface CountResponse {
     scannedPrefixes: number;
     scannedObjects: number;
     matched: number;
+    retries?: number;
+    cacheHits?: number;
+    cacheMisses?: number;
+    prunedPrefixes?: number;
   };
 }
+
+export type SegmentListing = 'literal' | 'delimited' | 'expanded' | 'flat';
+
+export interface ExplainResponse {
+  mode: QueryMode;
+  scheme: string;
+  bucket: string;
+  captureNames: string[];
+  captureTypes: Record<string, CaptureType>;
+  literalPrefix: string;
+  matcher: string;
+  segments: {
+    index: number;
+    raw: string;
+    regex: string;
+    captureNames: string[] | null;
+    literalPrefixes: string[];
+    values?: number;
+    listing: SegmentListing;
+  }[];
+  initialJobs: { kind: 'segment' | 'objects'; segmentIndex: number; prefix: string; delimiter?: string }[];
+  initialJobCount: number;
+  warnings: string[];
+}
+
+export type ApiErrorCode =
+  | 'invalid_request'
+  | 'invalid_page_token'
+  | 'permission_denied'
+  | 'bucket_not_found'
+  | 'object_not_found'
+  | 'invalid_range'
+  | 'unsupported_format'
+  | 'image_too_large'
+  | 'rate_limited'
+  | 'timeout'
+  | 'internal';
+
+export interface ApiError {
+  error: string;
+  code?: ApiErrorCode;
+}
+
+export interface FacetValue {
+  value: CaptureValue;
+  count: number;
+}
+
+export interface Facet {
+  capture: string;
+  type: CaptureType;
+  values: FacetValue[];
+  truncated?: boolean;
+}
+
+export interface FacetsResponse {
+  facets: Facet[];
+  total: number;
+}